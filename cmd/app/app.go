@@ -12,9 +12,12 @@ import (
 	"github.com/adexcell/shortener/internal/adapter/postgres"
 	"github.com/adexcell/shortener/internal/adapter/redis"
 	"github.com/adexcell/shortener/internal/controller"
+	"github.com/adexcell/shortener/internal/domain"
 	"github.com/adexcell/shortener/internal/usecase"
+	"github.com/adexcell/shortener/pkg/geoip"
 	"github.com/adexcell/shortener/pkg/httpserver"
 	"github.com/adexcell/shortener/pkg/log"
+	pgdb "github.com/adexcell/shortener/pkg/postgres"
 	"github.com/adexcell/shortener/pkg/router"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -36,10 +39,15 @@ func NewApp() (*App, error) {
 
 	log := log.New()
 
+	r, err := router.New(cfg.Router)
+	if err != nil {
+		return nil, err
+	}
+
 	return &App{
 		cfg:    cfg,
 		log:    log,
-		router: router.New(cfg.Router),
+		router: r,
 	}, nil
 }
 
@@ -66,18 +74,33 @@ func (a *App) Run() error {
 }
 
 func (a *App) initDependencies() error {
-	storage, err := postgres.New(a.cfg.Postgres)
+	aliases := domain.NewAliasPolicy(a.cfg.Alias.Case == "lower", a.cfg.Alias.Reserved)
+
+	db, err := pgdb.New(a.cfg.Postgres)
 	if err != nil {
 		return fmt.Errorf("Failed to init Postgres: %w", err)
 	}
+	storage := postgres.New(db)
 	a.addCloser(storage.Close)
+	apiKeyStorage := postgres.NewAPIKeyPostgres(db)
 
 	redis := redis.New(a.cfg.Redis)
 	a.addCloser(redis.Close)
 
-	shortenerUsecase := usecase.New(storage, redis, a.log, a.cfg.Redis.TTL)
+	generator, err := usecase.NewGenerator(a.cfg.Shortener, storage)
+	if err != nil {
+		return fmt.Errorf("Failed to init code generator: %w", err)
+	}
+
+	shortenerUsecase := usecase.New(storage, redis, generator, a.initGeoIP(), a.log, a.cfg.Redis.TTL, a.cfg.Shortener, aliases)
 	a.addCloser(shortenerUsecase.Close)
-	shortenHandler := controller.NewShortenHandler(shortenerUsecase, a.log)
+	shortenHandler := controller.NewShortenHandler(shortenerUsecase, a.cfg.Redirect, aliases, a.log)
+
+	apiKeyUsecase := usecase.NewAPIKeyUsecase(apiKeyStorage, a.log)
+	adminHandler := controller.NewAdminHandler(apiKeyUsecase, a.cfg.Auth.AdminToken, a.log)
+
+	// API-ключ определяет владельца ссылок; должен стоять до регистрации маршрутов.
+	a.router.Use(controller.Auth(apiKeyUsecase, a.cfg.Auth.AdminToken, a.log))
 
 	a.router.Static("/static", "./static")
 	a.router.StaticFile("/", "./static/index.html")
@@ -88,9 +111,30 @@ func (a *App) initDependencies() error {
 	a.log.Info().Msg("register shorten handler")
 	shortenHandler.Register(a.router)
 
+	a.log.Info().Msg("register admin handler")
+	adminHandler.Register(a.router)
+
 	return nil
 }
 
+// initGeoIP открывает GeoIP-базу. Без нее сервис работает, только правила
+// таргетинга по странам не срабатывают, а страна клика не записывается.
+func (a *App) initGeoIP() domain.GeoLocator {
+	path := a.cfg.GeoIP.Path
+	if path == "" {
+		return nil
+	}
+
+	reader, err := geoip.Open(path)
+	if err != nil {
+		a.log.Warn().Err(err).Str("path", path).Msg("geoip database unavailable, geo targeting disabled")
+		return nil
+	}
+	a.addCloser(reader.Close)
+
+	return reader
+}
+
 func (a *App) addCloser(closer func() error) {
 	a.closers = append(a.closers, closer)
 }
@@ -12,6 +12,11 @@ import (
 // @host            localhost:8080
 // @BasePath        /
 
+// @securityDefinitions.apikey BearerAuth
+// @in                         header
+// @name                       Authorization
+// @description                API key: "Bearer sk_..."
+
 func main() {
 	app, err := app.NewApp()
 	if err != nil {
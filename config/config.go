@@ -1,10 +1,16 @@
 package config
 
 import (
+	"fmt"
+
+	"github.com/adexcell/shortener/internal/controller"
+	"github.com/adexcell/shortener/internal/usecase"
+	"github.com/adexcell/shortener/pkg/geoip"
 	"github.com/adexcell/shortener/pkg/httpserver"
 	"github.com/adexcell/shortener/pkg/postgres"
 	"github.com/adexcell/shortener/pkg/redis"
 	"github.com/adexcell/shortener/pkg/router"
+	"github.com/go-playground/validator/v10"
 	"github.com/wb-go/wbf/config"
 )
 
@@ -14,6 +20,11 @@ type Config struct {
 	Router     router.Config
 	Postgres   postgres.Config
 	Redis      redis.Config
+	Auth       Auth
+	Shortener  usecase.Config
+	Redirect   controller.Config
+	Alias      Alias
+	GeoIP      geoip.Config
 }
 
 type App struct {
@@ -21,9 +32,38 @@ type App struct {
 	Version string `mapstructure:"app_version"`
 }
 
+type Auth struct {
+	// AdminToken открывает доступ к /admin и к ссылкам без владельца. Пустое
+	// значение выключает админку.
+	AdminToken string `mapstructure:"admin_token"`
+}
+
+type Alias struct {
+	// Case - политика регистра: preserve (как ввел пользователь) или lower.
+	Case string `mapstructure:"case" validate:"oneof=preserve lower"`
+	// Reserved дополняет встроенный список запрещенных алиасов.
+	Reserved []string `mapstructure:"reserved"`
+}
+
+// defaults - значения ключей, без которых сервис не работает, на случай
+// если их нет ни в config.yaml, ни в окружении.
+var defaults = map[string]any{
+	"shortener.generator":     "random",
+	"shortener.code_length":   6,
+	"shortener.max_attempts":  5,
+	"redirect.not_yet_active": "page",
+	"alias.case":              "preserve",
+}
+
+// Load читает конфиг из config/config.yaml, .env и окружения и проверяет
+// его по тегам validate: сервис с неверным конфигом не запускается.
 func Load() (*Config, error) {
 	cfg := config.New()
 
+	for key, value := range defaults {
+		cfg.SetDefault(key, value)
+	}
+
 	cfg.EnableEnv("")
 
 	_ = cfg.LoadEnvFiles(".env")
@@ -37,5 +77,9 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	if err := validator.New().Struct(res); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &res, nil
 }
@@ -15,9 +15,139 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/api-keys": {
+            "post": {
+                "description": "Issue a new API key for an owner. The key is shown only once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Create API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Key owner",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controller.createAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/controller.apiKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/api-keys/{id}": {
+            "delete": {
+                "description": "Revoke an API key; requests with it are rejected immediately",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Revoke API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/analytics/{short_url}": {
             "get": {
-                "description": "Get click statistics for a short URL",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get click statistics for a short URL over a period, with a zero-filled time series of clicks.\nBot clicks are excluded from totals and breakdowns unless include_bots is set; bot_clicks and by_bot always count them",
                 "produces": [
                     "application/json"
                 ],
@@ -32,6 +162,42 @@ const docTemplate = `{
                         "name": "short_url",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Period start, RFC 3339 or date (2006-01-02) in tz; default - the last 24 hours, 7 days, 13 weeks or 12 months by granularity",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Period end (exclusive), RFC 3339 or date in tz; default - now",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Series step: hour, day (default), week or month",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of the buckets, e.g. Europe/Moscow (default UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count bot clicks in totals and breakdowns (default false)",
+                        "name": "include_bots",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin token, required for links without an owner",
+                        "name": "X-Admin-Token",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -41,6 +207,33 @@ const docTemplate = `{
                             "$ref": "#/definitions/controller.statsControllerDTO"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -53,16 +246,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/s/{short_url}": {
+        "/links/{short_url}": {
             "get": {
-                "description": "Redirect user to the original long URL based on the short alias",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a short link with its attributes without counting a click",
                 "produces": [
-                    "text/html"
+                    "application/json"
                 ],
                 "tags": [
-                    "shortener"
+                    "links"
                 ],
-                "summary": "Redirect to original URL",
+                "summary": "Get link",
                 "parameters": [
                     {
                         "type": "string",
@@ -70,13 +268,28 @@ const docTemplate = `{
                         "name": "short_url",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin token, required for links without an owner",
+                        "name": "X-Admin-Token",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "302": {
-                        "description": "Redirect to original URL",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/controller.shortenerControllerDTO"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "404": {
@@ -87,13 +300,84 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
-            }
-        },
-        "/shorten": {
-            "post": {
-                "description": "Generate a short alias for a given long URL",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft-delete a short link; the code stops redirecting immediately",
+                "tags": [
+                    "links"
+                ],
+                "summary": "Delete link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL alias",
+                        "name": "short_url",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin token, required for links without an owner",
+                        "name": "X-Admin-Token",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change the destination, redirect settings, UTM template, targeting rules, rotation variants, password, title, interstitial, click limit, activation window (null clears active_from or expires_at) of a short link, or disable/enable it",
                 "consumes": [
                     "application/json"
                 ],
@@ -101,23 +385,42 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "shortener"
+                    "links"
                 ],
-                "summary": "Shorten URL",
+                "summary": "Update link",
                 "parameters": [
                     {
-                        "description": "URL to shorten",
+                        "type": "string",
+                        "description": "Short URL alias",
+                        "name": "short_url",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to update",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/controller.shortenRequest"
+                            "$ref": "#/definitions/controller.updateLinkRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin token, required for links without an owner",
+                        "name": "X-Admin-Token",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controller.shortenerControllerDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -125,8 +428,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -154,35 +466,913 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/qr/{short_url}": {
+            "get": {
+                "description": "Render a QR code with the full short URL in PNG or SVG. Scans are marked with _src=qr and counted in by_source.\nA link that exists but does not redirect yet or anymore still gets a code, so it can be printed before active_from.",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "shortener"
+                ],
+                "summary": "QR code of a short URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL alias",
+                        "name": "short_url",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "png (default) or svg",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image side in pixels, 64-2048 (default 256)",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Quiet zone in modules, 0-16 (default 4)",
+                        "name": "margin",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Error correction level: L, M (default), Q or H",
+                        "name": "level",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Foreground color, hex RRGGBB (default 000000)",
+                        "name": "fg",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Background color, hex RRGGBB (default ffffff)",
+                        "name": "bg",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached image",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/s/{short_url}": {
+            "get": {
+                "description": "Redirect user to the original long URL based on the short alias.\nQuery parameters and a trailing path (/s/{short_url}/extra/path) are passed to the destination if the link allows it.\nA password protected link renders a password form instead; the form is posted back to the same address.\nA short_url ending with \"+\" or ?preview=1 renders a preview page with the destination without counting a click,\nand links with interstitial_seconds render a countdown page before redirecting.\nClicks from known bots, link unfurlers and HEAD requests are marked as bot clicks in analytics",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "shortener"
+                ],
+                "summary": "Redirect to original URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL alias",
+                        "name": "short_url",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of a protected link (POST only)",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "1 - show the preview page instead of redirecting",
+                        "name": "preview",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Preview or interstitial page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "204": {
+                        "description": "Bot or HEAD request to a link with max_clicks: no Location, no click consumed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "301": {
+                        "description": "Permanent redirect to original URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "302": {
+                        "description": "Redirect to original URL, or to the configured fallback URL for an unknown or dead code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "303": {
+                        "description": "Redirect after a correct password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "Temporary redirect preserving the method",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "308": {
+                        "description": "Permanent redirect preserving the method",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Password form",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Link is not active yet (active_from)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "Link expired or its max_clicks limit is reached",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many password attempts",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Redirect user to the original long URL based on the short alias.\nQuery parameters and a trailing path (/s/{short_url}/extra/path) are passed to the destination if the link allows it.\nA password protected link renders a password form instead; the form is posted back to the same address.\nA short_url ending with \"+\" or ?preview=1 renders a preview page with the destination without counting a click,\nand links with interstitial_seconds render a countdown page before redirecting.\nClicks from known bots, link unfurlers and HEAD requests are marked as bot clicks in analytics",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "shortener"
+                ],
+                "summary": "Redirect to original URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL alias",
+                        "name": "short_url",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of a protected link (POST only)",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "1 - show the preview page instead of redirecting",
+                        "name": "preview",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Preview or interstitial page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "204": {
+                        "description": "Bot or HEAD request to a link with max_clicks: no Location, no click consumed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "301": {
+                        "description": "Permanent redirect to original URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "302": {
+                        "description": "Redirect to original URL, or to the configured fallback URL for an unknown or dead code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "303": {
+                        "description": "Redirect after a correct password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "Temporary redirect preserving the method",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "308": {
+                        "description": "Permanent redirect preserving the method",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Password form",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Link is not active yet (active_from)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "Link expired or its max_clicks limit is reached",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many password attempts",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Redirect user to the original long URL based on the short alias.\nQuery parameters and a trailing path (/s/{short_url}/extra/path) are passed to the destination if the link allows it.\nA password protected link renders a password form instead; the form is posted back to the same address.\nA short_url ending with \"+\" or ?preview=1 renders a preview page with the destination without counting a click,\nand links with interstitial_seconds render a countdown page before redirecting.\nClicks from known bots, link unfurlers and HEAD requests are marked as bot clicks in analytics",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "shortener"
+                ],
+                "summary": "Redirect to original URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL alias",
+                        "name": "short_url",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of a protected link (POST only)",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "1 - show the preview page instead of redirecting",
+                        "name": "preview",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Preview or interstitial page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "204": {
+                        "description": "Bot or HEAD request to a link with max_clicks: no Location, no click consumed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "301": {
+                        "description": "Permanent redirect to original URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "302": {
+                        "description": "Redirect to original URL, or to the configured fallback URL for an unknown or dead code",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "303": {
+                        "description": "Redirect after a correct password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "Temporary redirect preserving the method",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "308": {
+                        "description": "Permanent redirect preserving the method",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Password form",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Link is not active yet (active_from)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "Link expired or its max_clicks limit is reached",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many password attempts",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/shorten": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Generate a short alias for a given long URL",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "shortener"
+                ],
+                "summary": "Shorten URL",
+                "parameters": [
+                    {
+                        "description": "URL to shorten",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controller.shortenRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replay the first response for repeated requests with this key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/shorten/batch": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create up to 1000 short links at once, at most 20 of them password protected. Each item gets its own status: created, conflict, invalid or error",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "shortener"
+                ],
+                "summary": "Shorten URLs in bulk",
+                "parameters": [
+                    {
+                        "description": "URLs to shorten",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/controller.shortenRequest"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controller.batchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "controller.apiKeyResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Key - открытый ключ, показывается только при создании",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "controller.batchItemResult": {
+            "type": "object",
+            "properties": {
+                "alias": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "short_url": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status - created, conflict, invalid или error",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "controller.batchResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controller.batchItemResult"
+                    }
+                }
+            }
+        },
+        "controller.createAPIKeyRequest": {
+            "type": "object",
+            "required": [
+                "owner_id"
+            ],
+            "properties": {
+                "name": {
+                    "description": "Name - описание ключа",
+                    "type": "string",
+                    "maxLength": 128
+                },
+                "owner_id": {
+                    "description": "OwnerID - команда или клиент, которому принадлежат ссылки, созданные с ключом",
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "controller.liveVisitorsDTO": {
+            "type": "object",
+            "properties": {
+                "daily_total": {
+                    "type": "integer"
+                },
+                "today": {
+                    "type": "integer"
+                }
+            }
+        },
         "controller.shortenRequest": {
             "type": "object",
             "required": [
                 "url"
             ],
             "properties": {
+                "active_from": {
+                    "description": "ActiveFrom - момент, с которого ссылка начинает редиректить (RFC 3339)",
+                    "type": "string"
+                },
+                "active_until": {
+                    "description": "ActiveUntil - синоним expires_at",
+                    "type": "string"
+                },
                 "alias": {
                     "description": "кастомное имя сокращенной ссылки",
                     "type": "string"
                 },
+                "expires_at": {
+                    "description": "ExpiresAt - момент, после которого ссылка перестает работать (RFC 3339)",
+                    "type": "string"
+                },
+                "interstitial_seconds": {
+                    "description": "InterstitialSeconds - показывать страницу с адресом и обратным\nотсчетом (до 30 секунд) перед редиректом",
+                    "type": "integer"
+                },
+                "max_clicks": {
+                    "description": "MaxClicks - сколько переходов разрешено по ссылке, например 1 для\nодноразовой; 0 - без ограничения",
+                    "type": "integer"
+                },
+                "password": {
+                    "description": "Password - пароль, который нужно ввести перед переходом (от 4 символов, не больше 72 байт в UTF-8)",
+                    "type": "string"
+                },
+                "path_passthrough": {
+                    "description": "PathPassthrough - дописывать хвост пути (/s/{code}/extra/path) к целевому URL",
+                    "type": "boolean"
+                },
+                "query_mode": {
+                    "description": "QueryMode - проброс query-параметров перехода: none (по умолчанию),\nkeep (при совпадении ключей побеждает сохраненный URL), override\n(побеждает переход) или append (значения объединяются)",
+                    "enum": [
+                        "none",
+                        "keep",
+                        "override",
+                        "append"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.QueryMode"
+                        }
+                    ]
+                },
+                "redirect_status": {
+                    "description": "RedirectStatus - код редиректа: 301, 302 (по умолчанию), 307 или 308",
+                    "type": "integer"
+                },
+                "sticky_variants": {
+                    "description": "StickyVariants - закреплять выбранный вариант за посетителем cookie",
+                    "type": "boolean"
+                },
+                "targets": {
+                    "description": "Targets - правила таргетинга по платформе и устройству, проверяются\nпо порядку; если ни одно не подошло, переход идет на url",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controller.targetRuleDTO"
+                    }
+                },
+                "title": {
+                    "description": "Title - подпись для страниц предпросмотра и перехода",
+                    "type": "string"
+                },
+                "ttl": {
+                    "description": "TTL - время жизни ссылки от момента создания, например \"72h\"",
+                    "type": "string"
+                },
                 "url": {
                     "description": "URL - полная ссылка",
                     "type": "string"
+                },
+                "utm": {
+                    "description": "UTM - метки, которые добавляются к целевому URL при переходе",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/controller.utmDTO"
+                        }
+                    ]
+                },
+                "variants": {
+                    "description": "Variants - варианты ротации с весами: переход, не попавший под\nправила таргетинга, уходит на вариант, выбранный пропорционально весу",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controller.variantDTO"
+                    }
+                }
+            }
+        },
+        "controller.shortenerControllerDTO": {
+            "type": "object",
+            "properties": {
+                "active_from": {
+                    "type": "string"
+                },
+                "clicks": {
+                    "description": "Clicks - сколько переходов из max_clicks уже израсходовано",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "disabled": {
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "interstitial_seconds": {
+                    "type": "integer"
+                },
+                "long_url": {
+                    "type": "string"
+                },
+                "max_clicks": {
+                    "type": "integer"
+                },
+                "password_protected": {
+                    "type": "boolean"
+                },
+                "path_passthrough": {
+                    "type": "boolean"
+                },
+                "query_mode": {
+                    "$ref": "#/definitions/domain.QueryMode"
+                },
+                "redirect_status": {
+                    "type": "integer"
+                },
+                "short_code": {
+                    "type": "string"
+                },
+                "sticky_variants": {
+                    "type": "boolean"
+                },
+                "targets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controller.targetRuleDTO"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "utm": {
+                    "$ref": "#/definitions/controller.utmDTO"
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controller.variantDTO"
+                    }
                 }
             }
         },
         "controller.statsControllerDTO": {
             "type": "object",
             "properties": {
+                "bot_clicks": {
+                    "type": "integer"
+                },
+                "by_bot": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
                 "by_browser": {
                     "type": "object",
                     "additionalProperties": {
                         "type": "integer"
                     }
                 },
-                "by_date": {
+                "by_campaign": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "by_device": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "by_os": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "by_referrer": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "by_source": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "by_variant": {
                     "type": "object",
                     "additionalProperties": {
                         "type": "integer"
@@ -191,22 +1381,251 @@ const docTemplate = `{
                 "clicked_at": {
                     "type": "string"
                 },
+                "daily_unique_visitors": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "granularity": {
+                    "type": "string",
+                    "enum": [
+                        "hour",
+                        "day",
+                        "week",
+                        "month"
+                    ]
+                },
                 "id": {
                     "type": "string"
                 },
+                "include_bots": {
+                    "type": "boolean"
+                },
                 "ip": {
                     "type": "string"
                 },
+                "live_visitors": {
+                    "$ref": "#/definitions/controller.liveVisitorsDTO"
+                },
+                "series": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controller.statsPoint"
+                    }
+                },
                 "short_code": {
                     "type": "string"
                 },
+                "to": {
+                    "type": "string"
+                },
                 "total_clicks": {
                     "type": "integer"
                 },
+                "tz": {
+                    "type": "string"
+                },
                 "user_agent": {
                     "type": "string"
                 }
             }
+        },
+        "controller.statsPoint": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "unique": {
+                    "type": "integer"
+                }
+            }
+        },
+        "controller.targetRuleDTO": {
+            "type": "object",
+            "properties": {
+                "country": {
+                    "description": "Country - ISO 3166-1 alpha-2 код страны, например \"DE\"",
+                    "type": "string"
+                },
+                "device": {
+                    "description": "Device - mobile, tablet или desktop",
+                    "type": "string",
+                    "enum": [
+                        "mobile",
+                        "tablet",
+                        "desktop"
+                    ]
+                },
+                "platform": {
+                    "description": "Platform - ios, android, windows, macos, linux или chromeos",
+                    "type": "string",
+                    "enum": [
+                        "ios",
+                        "android",
+                        "windows",
+                        "macos",
+                        "linux",
+                        "chromeos"
+                    ]
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "controller.updateLinkRequest": {
+            "type": "object",
+            "properties": {
+                "active_from": {
+                    "description": "ActiveFrom переносит момент, с которого ссылка начинает редиректить;\nnull снимает эмбарго",
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "active_until": {
+                    "description": "ActiveUntil - синоним expires_at",
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "disabled": {
+                    "description": "Disabled - выключить (true) или включить (false) редирект",
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt переносит момент, после которого ссылка перестает работать;\nnull делает ссылку бессрочной",
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "interstitial_seconds": {
+                    "description": "InterstitialSeconds - секунды страницы перехода; 0 выключает ее",
+                    "type": "integer"
+                },
+                "max_clicks": {
+                    "description": "MaxClicks задает лимит переходов; 0 снимает его",
+                    "type": "integer"
+                },
+                "password": {
+                    "description": "Password задает новый пароль перехода; пустая строка снимает защиту",
+                    "type": "string"
+                },
+                "path_passthrough": {
+                    "description": "PathPassthrough - включить или выключить проброс хвоста пути",
+                    "type": "boolean"
+                },
+                "query_mode": {
+                    "description": "QueryMode - новый режим проброса query-параметров: none, keep, override или append",
+                    "enum": [
+                        "none",
+                        "keep",
+                        "override",
+                        "append"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.QueryMode"
+                        }
+                    ]
+                },
+                "redirect_status": {
+                    "description": "RedirectStatus - новый код редиректа: 301, 302, 307 или 308",
+                    "type": "integer"
+                },
+                "sticky_variants": {
+                    "description": "StickyVariants - закреплять ли вариант за посетителем cookie",
+                    "type": "boolean"
+                },
+                "targets": {
+                    "description": "Targets заменяет правила таргетинга целиком; пустой список удаляет их",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controller.targetRuleDTO"
+                    }
+                },
+                "title": {
+                    "description": "Title задает подпись ссылки; пустая строка удаляет ее",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "URL - новая полная ссылка",
+                    "type": "string"
+                },
+                "utm": {
+                    "description": "UTM заменяет шаблон меток целиком; пустой объект удаляет метки",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/controller.utmDTO"
+                        }
+                    ]
+                },
+                "variants": {
+                    "description": "Variants заменяет варианты ротации целиком; пустой список удаляет их",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controller.variantDTO"
+                    }
+                }
+            }
+        },
+        "controller.utmDTO": {
+            "type": "object",
+            "properties": {
+                "campaign": {
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "medium": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "term": {
+                    "type": "string"
+                }
+            }
+        },
+        "controller.variantDTO": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "weight": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.QueryMode": {
+            "type": "string",
+            "enum": [
+                "none",
+                "keep",
+                "override",
+                "append"
+            ],
+            "x-enum-varnames": [
+                "QueryDrop",
+                "QueryKeep",
+                "QueryOverride",
+                "QueryAppend"
+            ]
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "API key: \"Bearer sk_...\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
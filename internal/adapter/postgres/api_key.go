@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/postgres"
+)
+
+type APIKeyPostgres struct {
+	db *postgres.DB
+}
+
+func NewAPIKeyPostgres(db *postgres.DB) domain.APIKeyPostgres {
+	return &APIKeyPostgres{db: db}
+}
+
+func (p *APIKeyPostgres) Save(ctx context.Context, k domain.APIKey) error {
+	dto := apiKeyToPostgresDTO(k)
+
+	query := `
+	INSERT INTO api_keys (id, owner_id, name, key_hash)
+	VALUES ($1, $2, $3, $4)`
+
+	_, err := p.db.ExecContext(ctx, query, dto.ID, dto.OwnerID, dto.Name, dto.KeyHash)
+	return err
+}
+
+func (p *APIKeyPostgres) GetByHash(ctx context.Context, keyHash string) (domain.APIKey, error) {
+	var dto apiKeyPostgresDTO
+	query := `
+	SELECT id, owner_id, name, key_hash, created_at, revoked_at FROM api_keys
+	WHERE key_hash = $1`
+	err := p.db.QueryRowContext(ctx, query, keyHash).Scan(
+		&dto.ID,
+		&dto.OwnerID,
+		&dto.Name,
+		&dto.KeyHash,
+		&dto.CreatedAt,
+		&dto.RevokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.APIKey{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+
+	return apiKeyToDomain(dto), nil
+}
+
+func (p *APIKeyPostgres) Revoke(ctx context.Context, id string) error {
+	query := `
+	UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND revoked_at IS NULL`
+
+	res, err := p.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
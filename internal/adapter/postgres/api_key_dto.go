@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/adexcell/shortener/internal/domain"
+)
+
+type apiKeyPostgresDTO struct {
+	ID        string       `db:"id"`
+	OwnerID   string       `db:"owner_id"`
+	Name      string       `db:"name"`
+	KeyHash   string       `db:"key_hash"`
+	CreatedAt time.Time    `db:"created_at"`
+	RevokedAt sql.NullTime `db:"revoked_at"`
+}
+
+func apiKeyToPostgresDTO(k domain.APIKey) apiKeyPostgresDTO {
+	return apiKeyPostgresDTO{
+		ID:      k.ID,
+		OwnerID: k.OwnerID,
+		Name:    k.Name,
+		KeyHash: k.KeyHash,
+	}
+}
+
+func apiKeyToDomain(dto apiKeyPostgresDTO) domain.APIKey {
+	return domain.APIKey{
+		ID:        dto.ID,
+		OwnerID:   dto.OwnerID,
+		Name:      dto.Name,
+		KeyHash:   dto.KeyHash,
+		CreatedAt: dto.CreatedAt,
+		RevokedAt: nullToTime(dto.RevokedAt),
+	}
+}
@@ -2,7 +2,12 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/adexcell/shortener/internal/domain"
 	"github.com/adexcell/shortener/pkg/postgres"
@@ -12,43 +17,352 @@ type ShortenerPostgres struct {
 	db *postgres.DB
 }
 
-func New(cfg postgres.Config) (domain.ShortenerPostgres, error) {
-	db, err := postgres.New(cfg)
-	return &ShortenerPostgres{db: db}, err
+func New(db *postgres.DB) domain.ShortenerPostgres {
+	return &ShortenerPostgres{db: db}
 }
 
-func (p *ShortenerPostgres) Save(ctx context.Context, shortCode, longURL string) error {
-	dto, err := shortenerToPostgresDTO(shortCode, longURL)
-	if err != nil {
-		return err
-	}
+func (p *ShortenerPostgres) Save(ctx context.Context, s domain.Shortener) error {
+	dto := shortenerToPostgresDTO(s)
 
 	query := `
-	INSERT INTO urls (id, short_code, long_url)
-	VALUES ($1, $2, $3)`
+	INSERT INTO urls (id, short_code, long_url, owner_id, expires_at, redirect_status, query_mode, path_passthrough, utm, targets, variants, sticky_variants, password_hash, title, interstitial_seconds, max_clicks, active_from)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
 
-	_, err = p.db.ExecContext(ctx, query, dto.ID, dto.ShortCode, dto.LongURL)
+	_, err := p.db.ExecContext(
+		ctx,
+		query,
+		dto.ID,
+		dto.ShortCode,
+		dto.LongURL,
+		dto.OwnerID,
+		dto.ExpiresAt,
+		dto.RedirectStatus,
+		dto.QueryMode,
+		dto.PathPassthrough,
+		dto.UTM,
+		dto.Targets,
+		dto.Variants,
+		dto.StickyVariants,
+		dto.PasswordHash,
+		dto.Title,
+		dto.InterstitialSeconds,
+		dto.MaxClicks,
+		dto.ActiveFrom,
+	)
 	return err
 }
 
-func (p *ShortenerPostgres) GetLongURL(ctx context.Context, shortCode string) (string, error) {
-	var longURL string
+// batchChunkSize ограничивает число строк в одном INSERT, чтобы не выйти
+// за предел параметров запроса Postgres.
+const batchChunkSize = 1000
+
+func (p *ShortenerPostgres) SaveBatch(ctx context.Context, links []domain.Shortener) (map[string]bool, error) {
+	inserted := make(map[string]bool, len(links))
+
+	err := p.db.WithTx(ctx, func(tx *sql.Tx) error {
+		for chunk := range slices.Chunk(links, batchChunkSize) {
+			query, args := batchInsertQuery(chunk)
+
+			rows, err := tx.QueryContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+
+			for rows.Next() {
+				var code string
+				if err := rows.Scan(&code); err != nil {
+					rows.Close()
+					return err
+				}
+				inserted[code] = true
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inserted, nil
+}
+
+// batchInsertQuery строит многострочный INSERT, который пропускает занятые
+// коды и возвращает вставленные.
+func batchInsertQuery(links []domain.Shortener) (string, []any) {
+	const columns = 17
+
+	var sb strings.Builder
+	sb.WriteString(`
+	INSERT INTO urls (id, short_code, long_url, owner_id, expires_at, redirect_status, query_mode, path_passthrough, utm, targets, variants, sticky_variants, password_hash, title, interstitial_seconds, max_clicks, active_from)
+	VALUES `)
+
+	args := make([]any, 0, len(links)*columns)
+	for i, s := range links {
+		dto := shortenerToPostgresDTO(s)
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for j := range columns {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "$%d", i*columns+j+1)
+		}
+		sb.WriteString(")")
+		args = append(args,
+			dto.ID,
+			dto.ShortCode,
+			dto.LongURL,
+			dto.OwnerID,
+			dto.ExpiresAt,
+			dto.RedirectStatus,
+			dto.QueryMode,
+			dto.PathPassthrough,
+			dto.UTM,
+			dto.Targets,
+			dto.Variants,
+			dto.StickyVariants,
+			dto.PasswordHash,
+			dto.Title,
+			dto.InterstitialSeconds,
+			dto.MaxClicks,
+			dto.ActiveFrom,
+		)
+	}
+	sb.WriteString(`
+	ON CONFLICT (short_code) DO NOTHING
+	RETURNING short_code`)
+
+	return sb.String(), args
+}
+
+// shortenerColumns - колонки urls в порядке, ожидаемом scanShortener.
+const shortenerColumns = `id, short_code, long_url, owner_id, expires_at, redirect_status,
+	query_mode, path_passthrough, utm, targets, variants, sticky_variants, password_hash,
+	title, interstitial_seconds, max_clicks, clicks, active_from, disabled, created_at`
+
+func scanShortener(row *sql.Row) (domain.Shortener, error) {
+	var dto shortenerPostgresDTO
+	err := row.Scan(
+		&dto.ID,
+		&dto.ShortCode,
+		&dto.LongURL,
+		&dto.OwnerID,
+		&dto.ExpiresAt,
+		&dto.RedirectStatus,
+		&dto.QueryMode,
+		&dto.PathPassthrough,
+		&dto.UTM,
+		&dto.Targets,
+		&dto.Variants,
+		&dto.StickyVariants,
+		&dto.PasswordHash,
+		&dto.Title,
+		&dto.InterstitialSeconds,
+		&dto.MaxClicks,
+		&dto.Clicks,
+		&dto.ActiveFrom,
+		&dto.Disabled,
+		&dto.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Shortener{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.Shortener{}, err
+	}
+
+	return shortenerToDomain(dto)
+}
+
+// Get читает ссылку с мастера. По ней редирект заполняет кэш, и строка с
+// отстающей реплики после UpdateLink или DeleteLink вернула бы в Redis
+// старую ссылку на весь срок жизни записи. Чтение по ключу дешевое;
+// реплики остаются для статистики.
+func (p *ShortenerPostgres) Get(ctx context.Context, shortCode string) (domain.Shortener, error) {
 	query := `
-	SELECT long_url FROM urls
+	SELECT ` + shortenerColumns + ` FROM urls
+	WHERE short_code = $1 AND deleted_at IS NULL`
+	return scanShortener(p.db.Master.QueryRowContext(ctx, query, shortCode))
+}
+
+// Owner ищет владельца кода без учета удаления: адрес-заглушка владельца
+// нужен и для удаленных ссылок.
+func (p *ShortenerPostgres) Owner(ctx context.Context, shortCode string) (string, error) {
+	query := `
+	SELECT owner_id FROM urls
 	WHERE short_code = $1`
-	err := p.db.QueryRowContext(ctx, query, shortCode).Scan(&longURL)
-	return longURL, err
+
+	var owner sql.NullString
+	err := p.db.QueryRowContext(ctx, query, shortCode).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return owner.String, nil
+}
+
+func (p *ShortenerPostgres) FindDuplicate(ctx context.Context, ownerID, longURL string) (domain.Shortener, error) {
+	query := `
+	SELECT ` + shortenerColumns + ` FROM urls
+	WHERE owner_id IS NOT DISTINCT FROM $1
+		AND md5(long_url) = md5($2)
+		AND long_url = $2
+		AND deleted_at IS NULL
+		AND NOT disabled
+		AND expires_at IS NULL
+		AND active_from IS NULL
+		AND redirect_status = $3
+		AND query_mode = $4
+		AND NOT path_passthrough
+		AND utm IS NULL
+		AND targets IS NULL
+		AND variants IS NULL
+		AND password_hash IS NULL
+		AND title IS NULL
+		AND interstitial_seconds = 0
+		AND max_clicks = 0
+	ORDER BY created_at
+	LIMIT 1`
+	return scanShortener(p.db.QueryRowContext(
+		ctx,
+		query,
+		stringToNull(ownerID),
+		longURL,
+		domain.DefaultRedirectStatus,
+		string(domain.QueryDrop),
+	))
+}
+
+// Update меняет ссылку и возвращает ее новое состояние. UPDATE ... RETURNING
+// выполняется на мастере: QueryRowContext отправил бы его на реплику.
+func (p *ShortenerPostgres) Update(ctx context.Context, shortCode string, upd domain.LinkUpdate) (domain.Shortener, error) {
+	dto := linkUpdateToPostgresDTO(upd)
+
+	query := `
+	UPDATE urls SET
+		long_url = COALESCE($2, long_url),
+		disabled = COALESCE($3, disabled),
+		redirect_status = COALESCE($4, redirect_status),
+		query_mode = COALESCE($5, query_mode),
+		path_passthrough = COALESCE($6, path_passthrough),
+		utm = CASE WHEN $7 THEN $8::jsonb ELSE utm END,
+		targets = CASE WHEN $9 THEN $10::jsonb ELSE targets END,
+		variants = CASE WHEN $11 THEN $12::jsonb ELSE variants END,
+		sticky_variants = COALESCE($13, sticky_variants),
+		password_hash = CASE WHEN $14 THEN $15 ELSE password_hash END,
+		title = CASE WHEN $16 THEN $17 ELSE title END,
+		interstitial_seconds = COALESCE($18, interstitial_seconds),
+		max_clicks = COALESCE($19, max_clicks),
+		active_from = CASE WHEN $20 THEN $21 ELSE active_from END,
+		expires_at = CASE WHEN $22 THEN $23 ELSE expires_at END
+	WHERE short_code = $1 AND deleted_at IS NULL
+	RETURNING ` + shortenerColumns
+	return scanShortener(p.db.Master.QueryRowContext(
+		ctx,
+		query,
+		shortCode,
+		dto.LongURL,
+		dto.Disabled,
+		dto.RedirectStatus,
+		dto.QueryMode,
+		dto.PathPassthrough,
+		dto.SetUTM,
+		dto.UTM,
+		dto.SetTargets,
+		dto.Targets,
+		dto.SetVariants,
+		dto.Variants,
+		dto.StickyVariants,
+		dto.SetPassword,
+		dto.PasswordHash,
+		dto.SetTitle,
+		dto.Title,
+		dto.InterstitialSeconds,
+		dto.MaxClicks,
+		dto.SetActiveFrom,
+		dto.ActiveFrom,
+		dto.SetExpiresAt,
+		dto.ExpiresAt,
+	))
 }
 
-func (p *ShortenerPostgres) SaveClick(ctx context.Context, shortCode, ip, userAgent string) error {
-	dto, err := statsToPostgresDTO(shortCode, ip, userAgent)
+// ConsumeClick засчитывает переход условным UPDATE: параллельные переходы
+// по одноразовой ссылке не могут израсходовать последний клик дважды.
+func (p *ShortenerPostgres) ConsumeClick(ctx context.Context, shortCode string) error {
+	query := `
+	UPDATE urls SET clicks = clicks + 1
+	WHERE short_code = $1
+		AND deleted_at IS NULL
+		AND (max_clicks = 0 OR clicks < max_clicks)`
+
+	res, err := p.db.ExecContext(ctx, query, shortCode)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrClicksExhausted
+	}
+
+	return nil
+}
+
+// Delete выполняет мягкое удаление: строка остается, чтобы код нельзя было
+// переиспользовать под другую ссылку.
+func (p *ShortenerPostgres) Delete(ctx context.Context, shortCode string) error {
+	query := `
+	UPDATE urls SET deleted_at = CURRENT_TIMESTAMP
+	WHERE short_code = $1 AND deleted_at IS NULL`
+
+	res, err := p.db.ExecContext(ctx, query, shortCode)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
+
+// NextID берет следующее значение последовательности кодов. nextval меняет
+// последовательность и на реплике не выполняется, поэтому запрос идет на мастер.
+func (p *ShortenerPostgres) NextID(ctx context.Context) (uint64, error) {
+	var id uint64
+	err := p.db.Master.QueryRowContext(ctx, `SELECT nextval('url_code_seq')`).Scan(&id)
+	return id, err
+}
+
+func (p *ShortenerPostgres) SaveClick(ctx context.Context, click domain.Stats) error {
+	dto, err := statsToPostgresDTO(click)
 	if err != nil {
 		return err
 	}
 
 	query := `
-	INSERT INTO analytics (id, short_code, ip, user_agent)
-	VALUES ($1, $2, $3, $4)`
+	INSERT INTO analytics (
+		id, short_code, ip, user_agent, utm_campaign, country, variant, source,
+		browser, browser_version, os, device, is_bot, bot, referrer, visitor_id
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
 
 	_, err = p.db.ExecContext(
 		ctx,
@@ -57,48 +371,158 @@ func (p *ShortenerPostgres) SaveClick(ctx context.Context, shortCode, ip, userAg
 		dto.ShortCode,
 		dto.IP,
 		dto.UserAgent,
+		dto.Campaign,
+		dto.Country,
+		dto.Variant,
+		dto.Source,
+		dto.Browser,
+		dto.BrowserVersion,
+		dto.OS,
+		dto.Device,
+		dto.Bot,
+		dto.BotName,
+		dto.Referrer,
+		dto.VisitorID,
 	)
 	return err
 }
 
-func (p *ShortenerPostgres) GetDetailedStats(ctx context.Context, shortCode string) (domain.Stats, error) {
+// GetDetailedStats считает статистику кликов за период q. Клики группируются
+// по началу интервала в часовом поясе q, но интервал определяется моментом
+// начала в UTC; пустые интервалы дозаполняются нулями уже в Go. Клики ботов попадают в счетчики и разбивки только с
+// q.IncludeBots, но всегда считаются в bot_clicks и by_bot.
+func (p *ShortenerPostgres) GetDetailedStats(ctx context.Context, shortCode string, q domain.StatsQuery) (domain.Stats, error) {
 	var dto statsPostgresDTO
-	dto.ByDate = make(map[string]int)
+	dto.ByDate = make(map[string]bucketPostgresDTO)
 	dto.ByBrowser = make(map[string]int)
+	dto.ByCampaign = make(map[string]int)
+	dto.ByVariant = make(map[string]int)
+	dto.BySource = make(map[string]int)
+	dto.ByOS = make(map[string]int)
+	dto.ByDevice = make(map[string]int)
+	dto.ByReferrer = make(map[string]int)
+	dto.ByBot = make(map[string]int)
 
 	// total clicks
 	query := `
-	WITH raw_stats AS (
-		-- Шаг 1: Берем все клики по коду один раз
+	WITH raw_clicks AS (
+		-- Шаг 1: Берем все клики по коду за период один раз
 		SELECT 
 			clicked_at, 
-			user_agent,
-			COUNT(*) OVER() as total_count -- считает общее кол-во строк во всем результате
+			-- клики до разбора User-Agent попадают в other
+			COALESCE(browser, 'other') as browser,
+			COALESCE(os, 'other') as os,
+			COALESCE(device, 'other') as device,
+			-- переходы без Referer считаются прямыми
+			COALESCE(referrer, 'direct') as referrer,
+			utm_campaign,
+			variant,
+			source,
+			visitor_id,
+			is_bot,
+			-- клики ботов до сохранения имени попадают в other
+			COALESCE(bot, 'other') as bot
 		FROM analytics
-		WHERE short_code = $1
+		WHERE short_code = $1 AND clicked_at >= $2 AND clicked_at < $3
+	),
+	raw_stats AS (
+		-- Клики ботов по умолчанию отбрасываем
+		SELECT
+			*,
+			COUNT(*) OVER() as total_count -- считает общее кол-во строк во всем результате
+		FROM raw_clicks
+		WHERE NOT is_bot OR $6::boolean
 	),
 	by_date AS (
-		-- Шаг 2: Группируем по датам
-		SELECT TO_CHAR(clicked_at, 'YYYY-MM-DD') as d, COUNT(*) as c
-		FROM raw_stats
+		-- Шаг 2: Группируем по интервалам в часовом поясе запроса. Ключ -
+		-- начало интервала в UTC: при переводе часов назад два разных часа
+		-- имеют одинаковое местное время.
+		SELECT
+			TO_CHAR(b AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS') as d,
+			COUNT(*) as c,
+			COUNT(DISTINCT visitor_id) as u -- клики без отпечатка не учитываются
+		FROM (
+			SELECT
+				CASE WHEN $4::text = 'hour'
+					-- час отсчитывается от самого момента клика: местное
+					-- время начала часа в эту ночь неоднозначно
+					THEN clicked_at - ((clicked_at AT TIME ZONE $5::text) - date_trunc('hour', clicked_at AT TIME ZONE $5::text))
+					ELSE date_trunc($4::text, clicked_at AT TIME ZONE $5::text) AT TIME ZONE $5::text
+				END as b,
+				visitor_id
+			FROM raw_stats
+		) buckets
 		GROUP BY d
-		ORDER BY d DESC
-		LIMIT 7
 	),
 	by_browser AS (
-		-- Шаг 3: Группируем по браузерам
-		SELECT user_agent as b, COUNT(*) as c
+		-- Шаг 3: Группируем по семействам браузеров, платформам и устройствам
+		SELECT browser as b, COUNT(*) as c
 		FROM raw_stats
 		GROUP BY b
+	),
+	by_os AS (
+		SELECT os as o, COUNT(*) as c
+		FROM raw_stats
+		GROUP BY o
+	),
+	by_device AS (
+		SELECT device as dv, COUNT(*) as c
+		FROM raw_stats
+		GROUP BY dv
+	),
+	by_referrer AS (
+		-- Шаг 4: Группируем по сайтам-источникам
+		SELECT referrer as r, COUNT(*) as c
+		FROM raw_stats
+		GROUP BY r
+	),
+	by_campaign AS (
+		-- Шаг 5: Группируем по кампаниям, клики без utm_campaign не учитываем
+		SELECT utm_campaign as k, COUNT(*) as c
+		FROM raw_stats
+		WHERE utm_campaign IS NOT NULL
+		GROUP BY k
+	),
+	by_variant AS (
+		-- Шаг 6: Группируем по вариантам ротации
+		SELECT variant as v, COUNT(*) as c
+		FROM raw_stats
+		WHERE variant IS NOT NULL
+		GROUP BY v
+	),
+	by_source AS (
+		-- Шаг 7: Группируем по каналам перехода (QR-код и т.п.)
+		SELECT source as s, COUNT(*) as c
+		FROM raw_stats
+		WHERE source IS NOT NULL
+		GROUP BY s
+	),
+	by_bot AS (
+		-- Шаг 8: Группируем клики ботов по имени независимо от include_bots
+		SELECT bot as bn, COUNT(*) as c
+		FROM raw_clicks
+		WHERE is_bot
+		GROUP BY bn
 	)
 	-- Собираем всё в одну строку
 	SELECT 
 		COALESCE((SELECT total_count FROM raw_stats LIMIT 1), 0) as total,
-		COALESCE((SELECT jsonb_object_agg(d, c) FROM by_date), '{}') as dates,
-		COALESCE((SELECT jsonb_object_agg(b, c) FROM by_browser), '{}') as browsers;`
+		(SELECT COUNT(DISTINCT visitor_id) FROM raw_stats) as daily_unique_visitors,
+		(SELECT COUNT(*) FROM raw_clicks WHERE is_bot) as bot_clicks,
+		COALESCE((SELECT jsonb_object_agg(d, jsonb_build_object('clicks', c, 'unique', u)) FROM by_date), '{}') as dates,
+		COALESCE((SELECT jsonb_object_agg(b, c) FROM by_browser), '{}') as browsers,
+		COALESCE((SELECT jsonb_object_agg(k, c) FROM by_campaign), '{}') as campaigns,
+		COALESCE((SELECT jsonb_object_agg(v, c) FROM by_variant), '{}') as variants,
+		COALESCE((SELECT jsonb_object_agg(s, c) FROM by_source), '{}') as sources,
+		COALESCE((SELECT jsonb_object_agg(o, c) FROM by_os), '{}') as oses,
+		COALESCE((SELECT jsonb_object_agg(dv, c) FROM by_device), '{}') as devices,
+		COALESCE((SELECT jsonb_object_agg(r, c) FROM by_referrer), '{}') as referrers,
+		COALESCE((SELECT jsonb_object_agg(bn, c) FROM by_bot), '{}') as bots;`
 
-	var dates, browsers []byte
-	err := p.db.QueryRowContext(ctx, query, shortCode).Scan(&dto.TotalClicks, &dates, &browsers)
+	var dates, browsers, campaigns, variants, sources, oses, devices, referrers, bots []byte
+	err := p.db.QueryRowContext(ctx, query, shortCode, q.From, q.To, q.Granularity, q.Location.String(), q.IncludeBots).Scan(
+		&dto.TotalClicks, &dto.DailyUniqueVisitors, &dto.BotClicks, &dates, &browsers, &campaigns, &variants, &sources, &oses, &devices, &referrers, &bots,
+	)
 	if err != nil {
 		return domain.Stats{}, err
 	}
@@ -109,8 +533,29 @@ func (p *ShortenerPostgres) GetDetailedStats(ctx context.Context, shortCode stri
 	if err := json.Unmarshal(browsers, &dto.ByBrowser); err != nil {
 		return domain.Stats{}, err
 	}
+	if err := json.Unmarshal(campaigns, &dto.ByCampaign); err != nil {
+		return domain.Stats{}, err
+	}
+	if err := json.Unmarshal(variants, &dto.ByVariant); err != nil {
+		return domain.Stats{}, err
+	}
+	if err := json.Unmarshal(sources, &dto.BySource); err != nil {
+		return domain.Stats{}, err
+	}
+	if err := json.Unmarshal(oses, &dto.ByOS); err != nil {
+		return domain.Stats{}, err
+	}
+	if err := json.Unmarshal(devices, &dto.ByDevice); err != nil {
+		return domain.Stats{}, err
+	}
+	if err := json.Unmarshal(referrers, &dto.ByReferrer); err != nil {
+		return domain.Stats{}, err
+	}
+	if err := json.Unmarshal(bots, &dto.ByBot); err != nil {
+		return domain.Stats{}, err
+	}
 
-	res := statsToDomain(dto)
+	res := statsToDomain(dto, q)
 
 	return res, nil
 }
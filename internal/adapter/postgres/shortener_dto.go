@@ -1,39 +1,303 @@
 package postgres
 
 import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/adexcell/shortener/internal/domain"
 )
 
 type shortenerPostgresDTO struct {
-	ID        string    `db:"id"`
-	ShortCode string    `db:"short_code"`
-	LongURL   string    `db:"long_url"`
-	CreatedAt time.Time `db:"created_at"`
+	ID                  string         `db:"id"`
+	ShortCode           string         `db:"short_code"`
+	LongURL             string         `db:"long_url"`
+	OwnerID             sql.NullString `db:"owner_id"`
+	ActiveFrom          sql.NullTime   `db:"active_from"`
+	ExpiresAt           sql.NullTime   `db:"expires_at"`
+	RedirectStatus      int            `db:"redirect_status"`
+	QueryMode           string         `db:"query_mode"`
+	PathPassthrough     bool           `db:"path_passthrough"`
+	UTM                 sql.NullString `db:"utm"`
+	Targets             sql.NullString `db:"targets"`
+	Variants            sql.NullString `db:"variants"`
+	StickyVariants      bool           `db:"sticky_variants"`
+	PasswordHash        sql.NullString `db:"password_hash"`
+	Title               sql.NullString `db:"title"`
+	InterstitialSeconds int            `db:"interstitial_seconds"`
+	MaxClicks           int            `db:"max_clicks"`
+	Clicks              int            `db:"clicks"`
+	Disabled            bool           `db:"disabled"`
+	CreatedAt           time.Time      `db:"created_at"`
 }
 
-func shortenerToPostgresDTO(shortCode, longURL string) (*shortenerPostgresDTO, error) {
-	s, err := domain.NewShortener(shortCode, longURL)
+type linkUpdatePostgresDTO struct {
+	LongURL         sql.NullString
+	Disabled        sql.NullBool
+	RedirectStatus  sql.NullInt32
+	QueryMode       sql.NullString
+	PathPassthrough sql.NullBool
+	// SetUTM отличает замену шаблона меток от его отсутствия в изменении.
+	SetUTM              bool
+	UTM                 sql.NullString
+	SetTargets          bool
+	Targets             sql.NullString
+	SetVariants         bool
+	Variants            sql.NullString
+	StickyVariants      sql.NullBool
+	SetPassword         bool
+	PasswordHash        sql.NullString
+	SetTitle            bool
+	Title               sql.NullString
+	InterstitialSeconds sql.NullInt32
+	MaxClicks           sql.NullInt32
+	SetActiveFrom       bool
+	ActiveFrom          sql.NullTime
+	SetExpiresAt        bool
+	ExpiresAt           sql.NullTime
+}
+
+// utmPostgresDTO - шаблон UTM-меток в колонке urls.utm (JSONB).
+type utmPostgresDTO struct {
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+	Term     string `json:"utm_term,omitempty"`
+	Content  string `json:"utm_content,omitempty"`
+}
+
+// targetRulePostgresDTO - правило таргетинга в колонке urls.targets (JSONB).
+type targetRulePostgresDTO struct {
+	Platform string `json:"platform,omitempty"`
+	Device   string `json:"device,omitempty"`
+	Country  string `json:"country,omitempty"`
+	URL      string `json:"url"`
+}
+
+// variantPostgresDTO - вариант ротации в колонке urls.variants (JSONB).
+type variantPostgresDTO struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+func shortenerToPostgresDTO(s domain.Shortener) *shortenerPostgresDTO {
+	return &shortenerPostgresDTO{
+		ID:                  s.ID,
+		ShortCode:           s.ShortCode,
+		LongURL:             s.LongURL,
+		OwnerID:             stringToNull(s.OwnerID),
+		ActiveFrom:          timeToNull(s.ActiveFrom),
+		ExpiresAt:           timeToNull(s.ExpiresAt),
+		RedirectStatus:      s.Redirect().StatusCode,
+		QueryMode:           string(cmp.Or(s.QueryMode, domain.QueryDrop)),
+		PathPassthrough:     s.PathPassthrough,
+		UTM:                 utmToJSON(s.UTM),
+		Targets:             targetsToJSON(s.Targets),
+		Variants:            variantsToJSON(s.Variants),
+		StickyVariants:      s.StickyVariants,
+		PasswordHash:        stringToNull(s.PasswordHash),
+		Title:               stringToNull(s.Title),
+		InterstitialSeconds: s.InterstitialSeconds,
+		MaxClicks:           s.MaxClicks,
+	}
+}
+
+func shortenerToDomain(dto shortenerPostgresDTO) (domain.Shortener, error) {
+	utm, err := jsonToUTM(dto.UTM)
+	if err != nil {
+		return domain.Shortener{}, err
+	}
+	targets, err := jsonToTargets(dto.Targets)
 	if err != nil {
-		return &shortenerPostgresDTO{}, err
+		return domain.Shortener{}, err
 	}
+	variants, err := jsonToVariants(dto.Variants)
+	if err != nil {
+		return domain.Shortener{}, err
+	}
+
+	return domain.Shortener{
+		ID:                  dto.ID,
+		ShortCode:           dto.ShortCode,
+		LongURL:             dto.LongURL,
+		OwnerID:             dto.OwnerID.String,
+		ActiveFrom:          nullToTime(dto.ActiveFrom),
+		ExpiresAt:           nullToTime(dto.ExpiresAt),
+		RedirectStatus:      dto.RedirectStatus,
+		QueryMode:           domain.QueryMode(dto.QueryMode),
+		PathPassthrough:     dto.PathPassthrough,
+		UTM:                 utm,
+		Targets:             targets,
+		Variants:            variants,
+		StickyVariants:      dto.StickyVariants,
+		PasswordHash:        dto.PasswordHash.String,
+		Title:               dto.Title.String,
+		InterstitialSeconds: dto.InterstitialSeconds,
+		MaxClicks:           dto.MaxClicks,
+		Clicks:              dto.Clicks,
+		Disabled:            dto.Disabled,
+		CreatedAt:           dto.CreatedAt,
+	}, nil
+}
 
-	res := &shortenerPostgresDTO{
-		ID:        s.ID,
-		ShortCode: s.ShortCode,
-		LongURL:   s.LongURL,
+func linkUpdateToPostgresDTO(upd domain.LinkUpdate) linkUpdatePostgresDTO {
+	var dto linkUpdatePostgresDTO
+	if upd.LongURL != nil {
+		dto.LongURL = sql.NullString{String: *upd.LongURL, Valid: true}
 	}
-	return res, nil
+	if upd.Disabled != nil {
+		dto.Disabled = sql.NullBool{Bool: *upd.Disabled, Valid: true}
+	}
+	if upd.RedirectStatus != nil {
+		dto.RedirectStatus = sql.NullInt32{Int32: int32(*upd.RedirectStatus), Valid: true}
+	}
+	if upd.QueryMode != nil {
+		dto.QueryMode = sql.NullString{String: string(*upd.QueryMode), Valid: true}
+	}
+	if upd.PathPassthrough != nil {
+		dto.PathPassthrough = sql.NullBool{Bool: *upd.PathPassthrough, Valid: true}
+	}
+	if upd.UTM != nil {
+		dto.SetUTM = true
+		dto.UTM = utmToJSON(*upd.UTM)
+	}
+	if upd.Targets != nil {
+		dto.SetTargets = true
+		dto.Targets = targetsToJSON(*upd.Targets)
+	}
+	if upd.Variants != nil {
+		dto.SetVariants = true
+		dto.Variants = variantsToJSON(*upd.Variants)
+	}
+	if upd.StickyVariants != nil {
+		dto.StickyVariants = sql.NullBool{Bool: *upd.StickyVariants, Valid: true}
+	}
+	if upd.PasswordHash != nil {
+		dto.SetPassword = true
+		dto.PasswordHash = stringToNull(*upd.PasswordHash)
+	}
+	if upd.Title != nil {
+		dto.SetTitle = true
+		dto.Title = stringToNull(*upd.Title)
+	}
+	if upd.InterstitialSeconds != nil {
+		dto.InterstitialSeconds = sql.NullInt32{Int32: int32(*upd.InterstitialSeconds), Valid: true}
+	}
+	if upd.MaxClicks != nil {
+		dto.MaxClicks = sql.NullInt32{Int32: int32(*upd.MaxClicks), Valid: true}
+	}
+	if upd.ActiveFrom != nil {
+		dto.SetActiveFrom = true
+		dto.ActiveFrom = timeToNull(upd.ActiveFrom.Time)
+	}
+	if upd.ExpiresAt != nil {
+		dto.SetExpiresAt = true
+		dto.ExpiresAt = timeToNull(upd.ExpiresAt.Time)
+	}
+	return dto
 }
 
-func shortenerToDomain(dto shortenerPostgresDTO) *domain.Shortener {
-	return &domain.Shortener{
-		ID:        dto.ID,
-		ShortCode: dto.ShortCode,
-		LongURL:   dto.LongURL,
-		CreatedAt: dto.CreatedAt,
+// utmToJSON кодирует шаблон меток; пустой шаблон хранится как NULL.
+// JSON передается строкой: []byte lib/pq отправил бы как bytea.
+func utmToJSON(u domain.UTM) sql.NullString {
+	if u.IsZero() {
+		return sql.NullString{}
 	}
+
+	b, _ := json.Marshal(utmPostgresDTO(u))
+	return sql.NullString{String: string(b), Valid: true}
 }
 
+func jsonToUTM(s sql.NullString) (domain.UTM, error) {
+	if !s.Valid {
+		return domain.UTM{}, nil
+	}
 
+	var dto utmPostgresDTO
+	if err := json.Unmarshal([]byte(s.String), &dto); err != nil {
+		return domain.UTM{}, err
+	}
+	return domain.UTM(dto), nil
+}
+
+// targetsToJSON кодирует правила таргетинга; пустой список хранится как NULL.
+func targetsToJSON(rules []domain.TargetRule) sql.NullString {
+	if len(rules) == 0 {
+		return sql.NullString{}
+	}
+
+	dto := make([]targetRulePostgresDTO, len(rules))
+	for i, r := range rules {
+		dto[i] = targetRulePostgresDTO(r)
+	}
+	b, _ := json.Marshal(dto)
+	return sql.NullString{String: string(b), Valid: true}
+}
+
+func jsonToTargets(s sql.NullString) ([]domain.TargetRule, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+
+	var dto []targetRulePostgresDTO
+	if err := json.Unmarshal([]byte(s.String), &dto); err != nil {
+		return nil, err
+	}
+
+	rules := make([]domain.TargetRule, len(dto))
+	for i, r := range dto {
+		rules[i] = domain.TargetRule(r)
+	}
+	return rules, nil
+}
+
+// variantsToJSON кодирует варианты ротации; пустой список хранится как NULL.
+func variantsToJSON(variants []domain.Variant) sql.NullString {
+	if len(variants) == 0 {
+		return sql.NullString{}
+	}
+
+	dto := make([]variantPostgresDTO, len(variants))
+	for i, v := range variants {
+		dto[i] = variantPostgresDTO(v)
+	}
+	b, _ := json.Marshal(dto)
+	return sql.NullString{String: string(b), Valid: true}
+}
+
+func jsonToVariants(s sql.NullString) ([]domain.Variant, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+
+	var dto []variantPostgresDTO
+	if err := json.Unmarshal([]byte(s.String), &dto); err != nil {
+		return nil, err
+	}
+
+	variants := make([]domain.Variant, len(dto))
+	for i, v := range dto {
+		variants[i] = domain.Variant(v)
+	}
+	return variants, nil
+}
+
+func stringToNull(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func timeToNull(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func nullToTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
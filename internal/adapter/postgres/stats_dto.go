@@ -1,50 +1,116 @@
 package postgres
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/adexcell/shortener/internal/domain"
 )
 
 type statsPostgresDTO struct {
-	ID          string `db:"id"`
-	ShortCode   string `db:"short_code"`
-	IP          string `db:"ip"`
-	UserAgent   string `db:"user_agent"`
-	TotalClicks int
-	ByDate      map[string]int
-	ByBrowser   map[string]int
-	ClickedAt   time.Time `db:"clicked_at"`
+	ID                  string         `db:"id"`
+	ShortCode           string         `db:"short_code"`
+	IP                  string         `db:"ip"`
+	UserAgent           string         `db:"user_agent"`
+	Campaign            sql.NullString `db:"utm_campaign"`
+	Country             sql.NullString `db:"country"`
+	Variant             sql.NullString `db:"variant"`
+	Source              sql.NullString `db:"source"`
+	Browser             sql.NullString `db:"browser"`
+	BrowserVersion      sql.NullString `db:"browser_version"`
+	OS                  sql.NullString `db:"os"`
+	Device              sql.NullString `db:"device"`
+	Bot                 bool           `db:"is_bot"`
+	BotName             sql.NullString `db:"bot"`
+	Referrer            sql.NullString `db:"referrer"`
+	VisitorID           sql.NullString `db:"visitor_id"`
+	TotalClicks         int
+	BotClicks           int
+	DailyUniqueVisitors int
+	// ByDate - клики по началу интервала в формате bucketLayout
+	ByDate     map[string]bucketPostgresDTO
+	ByBrowser  map[string]int
+	ByCampaign map[string]int
+	ByVariant  map[string]int
+	BySource   map[string]int
+	ByOS       map[string]int
+	ByDevice   map[string]int
+	ByReferrer map[string]int
+	ByBot      map[string]int
+	ClickedAt  time.Time `db:"clicked_at"`
 }
 
-func statsToPostgresDTO(shortCode, ip, userAgent string) (*statsPostgresDTO, error) {
-	s, err := domain.NewStats(shortCode, ip, userAgent)
+func statsToPostgresDTO(click domain.Stats) (*statsPostgresDTO, error) {
+	s, err := domain.NewStats(click.ShortCode, click.IP, click.UserAgent)
 	if err != nil {
 		return &statsPostgresDTO{}, err
 	}
 
 	res := &statsPostgresDTO{
-		ID:          s.ID,
-		ShortCode:   s.ShortCode,
-		IP:          s.IP,
-		UserAgent:   s.UserAgent,
-		TotalClicks: s.TotalClicks,
-		ByDate:      s.ByDate,
-		ByBrowser:   s.ByBrowser,
-		ClickedAt:   s.ClickedAt,
+		ID:             s.ID,
+		ShortCode:      s.ShortCode,
+		IP:             s.IP,
+		UserAgent:      s.UserAgent,
+		Campaign:       stringToNull(click.Campaign),
+		Country:        stringToNull(click.Country),
+		Variant:        stringToNull(click.Variant),
+		Source:         stringToNull(click.Source),
+		Browser:        stringToNull(click.Browser),
+		BrowserVersion: stringToNull(click.BrowserVersion),
+		OS:             stringToNull(click.OS),
+		Device:         stringToNull(click.Device),
+		Bot:            click.Bot,
+		BotName:        stringToNull(click.BotName),
+		Referrer:       stringToNull(click.Referrer),
+		VisitorID:      stringToNull(click.VisitorID),
+		TotalClicks:    s.TotalClicks,
+		ByBrowser:      s.ByBrowser,
+		ClickedAt:      s.ClickedAt,
 	}
 	return res, nil
 }
 
-func statsToDomain(dto statsPostgresDTO) domain.Stats {
+// bucketPostgresDTO - клики и уникальные посетители за интервал.
+type bucketPostgresDTO struct {
+	Clicks int `json:"clicks"`
+	Unique int `json:"unique"`
+}
+
+// bucketLayout - начало интервала в by_date: время UTC, как его форматирует
+// TO_CHAR. В местное время интервал переводится только в ответе.
+const bucketLayout = "2006-01-02T15:04:05"
+
+func statsToDomain(dto statsPostgresDTO, q domain.StatsQuery) domain.Stats {
 	return domain.Stats{
-		ID:          dto.ID,
-		ShortCode:   dto.ShortCode,
-		IP:          dto.IP,
-		UserAgent:   dto.UserAgent,
-		TotalClicks: dto.TotalClicks,
-		ByDate:      dto.ByDate,
-		ByBrowser:   dto.ByBrowser,
-		ClickedAt:   dto.ClickedAt,
+		ID:                  dto.ID,
+		ShortCode:           dto.ShortCode,
+		IP:                  dto.IP,
+		UserAgent:           dto.UserAgent,
+		TotalClicks:         dto.TotalClicks,
+		BotClicks:           dto.BotClicks,
+		DailyUniqueVisitors: dto.DailyUniqueVisitors,
+		Series:              seriesToDomain(dto.ByDate, q),
+		ByBrowser:           dto.ByBrowser,
+		ByCampaign:          dto.ByCampaign,
+		ByVariant:           dto.ByVariant,
+		BySource:            dto.BySource,
+		ByOS:                dto.ByOS,
+		ByDevice:            dto.ByDevice,
+		ByReferrer:          dto.ByReferrer,
+		ByBot:               dto.ByBot,
+		ClickedAt:           dto.ClickedAt,
 	}
 }
+
+// seriesToDomain раскладывает клики по интервалам периода, заполняя пустые
+// интервалы нулями.
+func seriesToDomain(buckets map[string]bucketPostgresDTO, q domain.StatsQuery) []domain.StatsPoint {
+	series := q.Series()
+	points := make([]domain.StatsPoint, len(series))
+	for i, t := range series {
+		b := buckets[t.UTC().Format(bucketLayout)]
+		points[i] = domain.StatsPoint{Time: t, Clicks: b.Clicks, Unique: b.Unique}
+	}
+
+	return points
+}
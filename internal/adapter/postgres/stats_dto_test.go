@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesToDomainDST(t *testing.T) {
+	// 2 ноября 2025 в Нью-Йорке часы переводятся назад: час 01:00 бывает
+	// дважды, и каждый должен получить свои клики.
+	q, err := domain.NewStatsQuery("2025-11-02", "2025-11-03", domain.GranularityHour, "America/New_York", time.Now())
+	assert.NoError(t, err)
+
+	buckets := map[string]bucketPostgresDTO{
+		"2025-11-02T05:00:00": {Clicks: 3, Unique: 2}, // 01:00 EDT
+		"2025-11-02T06:00:00": {Clicks: 5, Unique: 4}, // 01:00 EST
+	}
+
+	points := seriesToDomain(buckets, q)
+
+	assert.Len(t, points, 25)
+	assert.Equal(t, "01:00 EDT", points[1].Time.Format("15:04 MST"))
+	assert.Equal(t, 3, points[1].Clicks)
+	assert.Equal(t, "01:00 EST", points[2].Time.Format("15:04 MST"))
+	assert.Equal(t, 5, points[2].Clicks)
+}
@@ -30,6 +30,58 @@ func (r *ShortenerRedis) Get(ctx context.Context, key string) (string, error) {
 	return r.redis.Get(ctx, key)
 }
 
+func (r *ShortenerRedis) SetNX(
+	ctx context.Context,
+	key string,
+	value any,
+	expiration time.Duration,
+) (bool, error) {
+	return r.redis.SetNX(ctx, key, value, expiration).Result()
+}
+
+func (r *ShortenerRedis) SetMany(ctx context.Context, entries []domain.CacheEntry) error {
+	_, err := r.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, e := range entries {
+			pipe.Set(ctx, e.Key, e.Value, e.TTL)
+		}
+		return nil
+	})
+	return err
+}
+
+// Incr увеличивает счетчик окна ttl. Счетчик создается вместе со сроком
+// жизни через SET NX EX в одной транзакции с INCR, поэтому не может остаться
+// бессрочным; INCR срок жизни не сбрасывает, и окно не сдвигается.
+func (r *ShortenerRedis) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, key, 0, ttl)
+		incr = pipe.Incr(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (r *ShortenerRedis) Del(ctx context.Context, key string) error {
+	return r.redis.Del(ctx, key)
+}
+
+func (r *ShortenerRedis) PFAdd(ctx context.Context, key, member string, ttl time.Duration) error {
+	_, err := r.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.PFAdd(ctx, key, member)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+func (r *ShortenerRedis) PFCount(ctx context.Context, key string) (int64, error) {
+	return r.redis.PFCount(ctx, key).Result()
+}
+
 func (r *ShortenerRedis) Close() error {
 	return r.redis.Close()
 }
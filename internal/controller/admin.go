@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/log"
+	"github.com/adexcell/shortener/pkg/router"
+)
+
+const (
+	apiKeysURL = "/admin/api-keys"
+	apiKeyURL  = "/admin/api-keys/:id"
+
+	adminTokenHeader = "X-Admin-Token"
+)
+
+type adminHandler struct {
+	apiKeys domain.APIKeyUsecase
+	token   string
+	log     log.Log
+}
+
+func NewAdminHandler(u domain.APIKeyUsecase, token string, l log.Log) router.Handler {
+	return &adminHandler{apiKeys: u, token: token, log: l}
+}
+
+func (h *adminHandler) Register(router *router.Router) {
+	router.POST(apiKeysURL, h.requireAdmin, h.CreateAPIKey)
+	router.DELETE(apiKeyURL, h.requireAdmin, h.RevokeAPIKey)
+}
+
+// requireAdmin пропускает только запросы с верным X-Admin-Token.
+// Если токен не задан в конфиге, админка недоступна.
+func (h *adminHandler) requireAdmin(c *router.Context) {
+	if !validAdminToken(c.GetHeader(adminTokenHeader), h.token) {
+		c.AbortWithStatusJSON(http.StatusForbidden, router.H{"error": domain.ErrForbidden.Error()})
+		return
+	}
+	c.Next()
+}
+
+// validAdminToken сравнивает токен за постоянное время. Пустой токен в
+// конфиге не совпадает ни с чем.
+func validAdminToken(token, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+type createAPIKeyRequest struct {
+	// OwnerID - команда или клиент, которому принадлежат ссылки, созданные с ключом
+	OwnerID string `json:"owner_id" binding:"required,max=64"`
+	// Name - описание ключа
+	Name string `json:"name" binding:"max=128"`
+}
+
+type apiKeyResponse struct {
+	ID      string `json:"id"`
+	OwnerID string `json:"owner_id"`
+	Name    string `json:"name"`
+	// Key - открытый ключ, показывается только при создании
+	Key string `json:"key"`
+}
+
+// CreateAPIKey godoc
+// @Summary      Create API key
+// @Description  Issue a new API key for an owner. The key is shown only once.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-Admin-Token header string true "Admin token"
+// @Param        input body createAPIKeyRequest true "Key owner"
+// @Success      201  {object}  apiKeyResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/api-keys [post]
+func (h *adminHandler) CreateAPIKey(c *router.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, router.H{"error": "invalid request"})
+		return
+	}
+
+	k, rawKey, err := h.apiKeys.Create(c.Request.Context(), req.OwnerID, req.Name)
+	if err != nil {
+		h.log.Error().Err(err).Msg("failed to create api key")
+		c.JSON(http.StatusInternalServerError, router.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, apiKeyResponse{
+		ID:      k.ID,
+		OwnerID: k.OwnerID,
+		Name:    k.Name,
+		Key:     rawKey,
+	})
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke API key
+// @Description  Revoke an API key; requests with it are rejected immediately
+// @Tags         admin
+// @Param        X-Admin-Token header string true "Admin token"
+// @Param        id path string true "API key ID"
+// @Success      204
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/api-keys/{id} [delete]
+func (h *adminHandler) RevokeAPIKey(c *router.Context) {
+	err := h.apiKeys.Revoke(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			c.JSON(http.StatusNotFound, router.H{"error": "api key not found"})
+			return
+		}
+		h.log.Error().Err(err).Msg("failed to revoke api key")
+		c.JSON(http.StatusInternalServerError, router.H{"error": "db error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/log"
+	"github.com/adexcell/shortener/pkg/router"
+)
+
+const bearerPrefix = "Bearer "
+
+// Auth разбирает заголовок "Authorization: Bearer <key>" и кладет владельца
+// ключа в контекст запроса. Запросы без заголовка проходят анонимно,
+// запросы с неверным или отозванным ключом отклоняются. Верный X-Admin-Token
+// помечает запрос как запрос администратора, неверный - отклоняется.
+func Auth(u domain.APIKeyUsecase, adminToken string, l log.Log) router.HandlerFunc {
+	return func(c *router.Context) {
+		if token := c.GetHeader(adminTokenHeader); token != "" {
+			if !validAdminToken(token, adminToken) {
+				c.AbortWithStatusJSON(http.StatusForbidden, router.H{"error": domain.ErrForbidden.Error()})
+				return
+			}
+			c.Request = c.Request.WithContext(domain.WithAdmin(c.Request.Context()))
+		}
+
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		if !strings.HasPrefix(header, bearerPrefix) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, router.H{"error": domain.ErrUnauthorized.Error()})
+			return
+		}
+
+		key, err := u.Authenticate(c.Request.Context(), strings.TrimPrefix(header, bearerPrefix))
+		if err != nil {
+			if errors.Is(err, domain.ErrUnauthorized) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, router.H{"error": domain.ErrUnauthorized.Error()})
+				return
+			}
+			l.Error().Err(err).Msg("failed to authenticate api key")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, router.H{"error": "db error"})
+			return
+		}
+
+		c.Request = c.Request.WithContext(domain.WithOwner(c.Request.Context(), key.OwnerID))
+		c.Next()
+	}
+}
@@ -0,0 +1,160 @@
+package controller_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adexcell/shortener/internal/controller"
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/log"
+	"github.com/adexcell/shortener/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// --- Mocks ---
+
+type MockAPIKeyUsecase struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyUsecase) Create(ctx context.Context, ownerID, name string) (domain.APIKey, string, error) {
+	args := m.Called(ctx, ownerID, name)
+	return args.Get(0).(domain.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyUsecase) Revoke(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyUsecase) Authenticate(ctx context.Context, rawKey string) (domain.APIKey, error) {
+	args := m.Called(ctx, rawKey)
+	return args.Get(0).(domain.APIKey), args.Error(1)
+}
+
+// --- Setup ---
+
+func setupAuthRouter(u domain.APIKeyUsecase) (*router.Router, *string) {
+	r := setupRouter()
+	r.Use(controller.Auth(u, "secret", log.New()))
+
+	owner := new(string)
+	r.GET("/whoami", func(c *router.Context) {
+		*owner = domain.OwnerFromContext(c.Request.Context())
+		if domain.IsAdmin(c.Request.Context()) {
+			*owner = "admin"
+		}
+		c.Status(http.StatusOK)
+	})
+	return r, owner
+}
+
+// --- Tests ---
+
+func TestAuth(t *testing.T) {
+	t.Run("anonymous", func(t *testing.T) {
+		mockUC := new(MockAPIKeyUsecase)
+		r, owner := setupAuthRouter(mockUC)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/whoami", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, *owner)
+		mockUC.AssertNotCalled(t, "Authenticate")
+	})
+
+	t.Run("valid key", func(t *testing.T) {
+		mockUC := new(MockAPIKeyUsecase)
+		r, owner := setupAuthRouter(mockUC)
+
+		mockUC.On("Authenticate", mock.Anything, "sk_good").Return(domain.APIKey{OwnerID: "marketing"}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/whoami", nil)
+		req.Header.Set("Authorization", "Bearer sk_good")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "marketing", *owner)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		mockUC := new(MockAPIKeyUsecase)
+		r, _ := setupAuthRouter(mockUC)
+
+		mockUC.On("Authenticate", mock.Anything, "sk_revoked").Return(domain.APIKey{}, domain.ErrUnauthorized)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/whoami", nil)
+		req.Header.Set("Authorization", "Bearer sk_revoked")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("admin token", func(t *testing.T) {
+		mockUC := new(MockAPIKeyUsecase)
+		r, owner := setupAuthRouter(mockUC)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/whoami", nil)
+		req.Header.Set("X-Admin-Token", "secret")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "admin", *owner)
+	})
+
+	t.Run("wrong admin token", func(t *testing.T) {
+		mockUC := new(MockAPIKeyUsecase)
+		r, _ := setupAuthRouter(mockUC)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/whoami", nil)
+		req.Header.Set("X-Admin-Token", "guess")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestAdminHandler(t *testing.T) {
+	log := log.New()
+
+	t.Run("wrong admin token", func(t *testing.T) {
+		mockUC := new(MockAPIKeyUsecase)
+		r := setupRouter()
+		controller.NewAdminHandler(mockUC, "secret", log).Register(r)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/admin/api-keys/some-id", nil)
+		req.Header.Set("X-Admin-Token", "guess")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockUC.AssertNotCalled(t, "Revoke")
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		mockUC := new(MockAPIKeyUsecase)
+		r := setupRouter()
+		controller.NewAdminHandler(mockUC, "secret", log).Register(r)
+
+		mockUC.On("Revoke", mock.Anything, "some-id").Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/admin/api-keys/some-id", nil)
+		req.Header.Set("X-Admin-Token", "secret")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+}
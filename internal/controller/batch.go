@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/router"
+)
+
+const (
+	postShortURLBatch = "/shorten/batch"
+
+	maxBatchSize = 1000
+	// maxBatchPasswords ограничивает защищенные паролем элементы пакета:
+	// каждый пароль - bcrypt-хэш на десятки миллисекунд процессора.
+	maxBatchPasswords = 20
+
+	batchStatusCreated  = "created"
+	batchStatusConflict = "conflict"
+	batchStatusInvalid  = "invalid"
+	batchStatusError    = "error"
+)
+
+// batchItemResult - итог по одному элементу пакета.
+type batchItemResult struct {
+	URL      string `json:"url"`
+	Alias    string `json:"alias,omitempty"`
+	ShortURL string `json:"short_url,omitempty"`
+	// Status - created, conflict, invalid или error
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchItemResult `json:"results"`
+}
+
+// PostShortURLBatch godoc
+// @Summary      Shorten URLs in bulk
+// @Description  Create up to 1000 short links at once, at most 20 of them password protected. Each item gets its own status: created, conflict, invalid or error
+// @Tags         shortener
+// @Accept       json
+// @Produce      json
+// @Param        input body []shortenRequest true "URLs to shorten"
+// @Success      200  {object}  batchResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /shorten/batch [post]
+func (h *handler) PostShortURLBatch(c *router.Context) {
+	// Тело разбирается без binding-валидации, чтобы ошибка в одном
+	// элементе не отклоняла весь пакет.
+	var items []shortenRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&items); err != nil {
+		c.JSON(http.StatusBadRequest, router.H{"error": "invalid request"})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, router.H{"error": "empty batch"})
+		return
+	}
+	if len(items) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, router.H{"error": "too many items in batch"})
+		return
+	}
+	protected := 0
+	for _, item := range items {
+		if item.Password != "" {
+			protected++
+		}
+	}
+	if protected > maxBatchPasswords {
+		c.JSON(http.StatusBadRequest, router.H{"error": "too many password protected items in batch"})
+		return
+	}
+
+	results := make([]batchItemResult, len(items))
+	links := make([]domain.Shortener, 0, len(items))
+	positions := make([]int, 0, len(items))
+	for i, item := range items {
+		results[i] = batchItemResult{URL: item.URL, Alias: item.Alias}
+
+		opts, err := item.options()
+		if err != nil {
+			results[i].Status, results[i].Error = batchStatusInvalid, err.Error()
+			continue
+		}
+
+		dto, err := shortenerToControllerDTO(h.aliases, item.Alias, item.URL, opts)
+		if err != nil {
+			results[i].Status, results[i].Error = batchStatusInvalid, err.Error()
+			continue
+		}
+
+		links = append(links, *shortenerToDomain(*dto))
+		positions = append(positions, i)
+	}
+
+	if len(links) > 0 {
+		created, err := h.usecase.ShortenBatch(c.Request.Context(), links)
+		if err != nil {
+			h.log.Error().Err(err).Int("count", len(links)).Msg("failed to shorten batch")
+			c.JSON(http.StatusInternalServerError, router.H{"error": "db error"})
+			return
+		}
+
+		for j, res := range created {
+			results[positions[j]].apply(res)
+		}
+	}
+
+	c.JSON(http.StatusOK, batchResponse{Results: results})
+}
+
+func (r *batchItemResult) apply(res domain.BatchResult) {
+	switch {
+	case res.Err == nil:
+		r.Status, r.ShortURL = batchStatusCreated, res.ShortCode
+	case errors.Is(res.Err, domain.ErrAlreadyExists):
+		r.Status, r.Error = batchStatusConflict, domain.ErrAlreadyExists.Error()
+	case errors.Is(res.Err, domain.ErrPasswordTooLong):
+		r.Status, r.Error = batchStatusInvalid, domain.ErrPasswordTooLong.Error()
+	case errors.Is(res.Err, domain.ErrCodeExhausted):
+		r.Status, r.Error = batchStatusError, domain.ErrCodeExhausted.Error()
+	default:
+		r.Status, r.Error = batchStatusError, "internal error"
+	}
+}
@@ -0,0 +1,22 @@
+package controller
+
+// Значения Config.NotYetActive.
+const (
+	notYetActivePage     = "page"
+	notYetActiveNotFound = "not_found"
+)
+
+// Config - настройки ответов на переходы по коротким ссылкам.
+type Config struct {
+	// NotYetActive - ответ на переход до active_from: page (по умолчанию) -
+	// страница «ссылка еще не активна», not_found - 404, как для
+	// несуществующей ссылки, чтобы не раскрывать ее до эмбарго.
+	NotYetActive string `mapstructure:"not_yet_active" validate:"omitempty,oneof=page not_found"`
+	// NotFoundPage - HTML-страница для перехода по несуществующему коду,
+	// например static/404.html. Пусто - JSON-ответ.
+	NotFoundPage string `mapstructure:"not_found_page"`
+	// BaseURL - внешний адрес сервиса для QR-кодов, например
+	// https://sho.rt. Пусто - схема и хост запроса; такие QR-коды кэшируются
+	// только клиентом.
+	BaseURL string `mapstructure:"base_url" validate:"omitempty,url"`
+}
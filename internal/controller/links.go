@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/router"
+)
+
+type updateLinkRequest struct {
+	// URL - новая полная ссылка
+	URL *string `json:"url"`
+	// Disabled - выключить (true) или включить (false) редирект
+	Disabled *bool `json:"disabled"`
+	// RedirectStatus - новый код редиректа: 301, 302, 307 или 308
+	RedirectStatus *int `json:"redirect_status"`
+	// QueryMode - новый режим проброса query-параметров: none, keep, override или append
+	QueryMode *domain.QueryMode `json:"query_mode" enums:"none,keep,override,append"`
+	// PathPassthrough - включить или выключить проброс хвоста пути
+	PathPassthrough *bool `json:"path_passthrough"`
+	// UTM заменяет шаблон меток целиком; пустой объект удаляет метки
+	UTM *utmDTO `json:"utm"`
+	// Targets заменяет правила таргетинга целиком; пустой список удаляет их
+	Targets *[]targetRuleDTO `json:"targets"`
+	// Variants заменяет варианты ротации целиком; пустой список удаляет их
+	Variants *[]variantDTO `json:"variants"`
+	// StickyVariants - закреплять ли вариант за посетителем cookie
+	StickyVariants *bool `json:"sticky_variants"`
+	// Password задает новый пароль перехода; пустая строка снимает защиту
+	Password *string `json:"password"`
+	// Title задает подпись ссылки; пустая строка удаляет ее
+	Title *string `json:"title"`
+	// InterstitialSeconds - секунды страницы перехода; 0 выключает ее
+	InterstitialSeconds *int `json:"interstitial_seconds"`
+	// MaxClicks задает лимит переходов; 0 снимает его
+	MaxClicks *int `json:"max_clicks"`
+	// ActiveFrom переносит момент, с которого ссылка начинает редиректить;
+	// null снимает эмбарго
+	ActiveFrom nullableTime `json:"active_from" swaggertype:"string" format:"date-time"`
+	// ExpiresAt переносит момент, после которого ссылка перестает работать;
+	// null делает ссылку бессрочной
+	ExpiresAt nullableTime `json:"expires_at" swaggertype:"string" format:"date-time"`
+	// ActiveUntil - синоним expires_at
+	ActiveUntil nullableTime `json:"active_until" swaggertype:"string" format:"date-time"`
+}
+
+// nullableTime - необязательный момент времени в PATCH: отсутствующее поле
+// не меняет значение, null очищает его.
+type nullableTime struct {
+	set  bool
+	time *time.Time
+}
+
+func (t *nullableTime) UnmarshalJSON(b []byte) error {
+	t.set = true
+	if string(b) == "null" {
+		t.time = nil
+		return nil
+	}
+
+	var v time.Time
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	t.time = &v
+
+	return nil
+}
+
+func (t nullableTime) toDomain() *domain.TimeUpdate {
+	if !t.set {
+		return nil
+	}
+	return &domain.TimeUpdate{Time: t.time}
+}
+
+func (r updateLinkRequest) toDomain() domain.LinkUpdate {
+	var utm *domain.UTM
+	if r.UTM != nil {
+		u := utmToDomain(r.UTM)
+		utm = &u
+	}
+	var targets *[]domain.TargetRule
+	if r.Targets != nil {
+		t := targetsToDomain(*r.Targets)
+		targets = &t
+	}
+	var variants *[]domain.Variant
+	if r.Variants != nil {
+		v := variantsToDomain(*r.Variants)
+		variants = &v
+	}
+	expiresAt := r.ExpiresAt
+	if r.ActiveUntil.set {
+		expiresAt = r.ActiveUntil
+	}
+
+	return domain.LinkUpdate{
+		LongURL:             r.URL,
+		Disabled:            r.Disabled,
+		RedirectStatus:      r.RedirectStatus,
+		QueryMode:           r.QueryMode,
+		PathPassthrough:     r.PathPassthrough,
+		UTM:                 utm,
+		Targets:             targets,
+		Variants:            variants,
+		StickyVariants:      r.StickyVariants,
+		Password:            r.Password,
+		Title:               r.Title,
+		InterstitialSeconds: r.InterstitialSeconds,
+		MaxClicks:           r.MaxClicks,
+		ActiveFrom:          r.ActiveFrom.toDomain(),
+		ExpiresAt:           expiresAt.toDomain(),
+	}
+}
+
+// GetLink godoc
+// @Summary      Get link
+// @Description  Get a short link with its attributes without counting a click
+// @Tags         links
+// @Produce      json
+// @Param        short_url path string true "Short URL alias"
+// @Success      200  {object}  shortenerControllerDTO
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Param        X-Admin-Token header string false "Admin token, required for links without an owner"
+// @Security     BearerAuth
+// @Router       /links/{short_url} [get]
+func (h *handler) GetLink(c *router.Context) {
+	code := c.Param("short_url")
+
+	link, err := h.usecase.GetLink(c.Request.Context(), code)
+	if err != nil {
+		h.linkError(c, err, "failed to get link")
+		return
+	}
+
+	c.JSON(http.StatusOK, shortenerToResponse(link))
+}
+
+// UpdateLink godoc
+// @Summary      Update link
+// @Description  Change the destination, redirect settings, UTM template, targeting rules, rotation variants, password, title, interstitial, click limit, activation window (null clears active_from or expires_at) of a short link, or disable/enable it
+// @Tags         links
+// @Accept       json
+// @Produce      json
+// @Param        short_url path string true "Short URL alias"
+// @Param        input body updateLinkRequest true "Fields to update"
+// @Success      200  {object}  shortenerControllerDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Param        X-Admin-Token header string false "Admin token, required for links without an owner"
+// @Security     BearerAuth
+// @Router       /links/{short_url} [patch]
+func (h *handler) UpdateLink(c *router.Context) {
+	code := c.Param("short_url")
+
+	var req updateLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, router.H{"error": "invalid request"})
+		return
+	}
+	if req.ExpiresAt.set && req.ActiveUntil.set {
+		c.JSON(http.StatusBadRequest, router.H{"error": "only one of expires_at or active_until can be set"})
+		return
+	}
+
+	upd := req.toDomain()
+	if err := upd.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, router.H{"error": err.Error()})
+		return
+	}
+
+	link, err := h.usecase.UpdateLink(c.Request.Context(), code, upd)
+	if err != nil {
+		h.linkError(c, err, "failed to update link")
+		return
+	}
+
+	c.JSON(http.StatusOK, shortenerToResponse(link))
+}
+
+// DeleteLink godoc
+// @Summary      Delete link
+// @Description  Soft-delete a short link; the code stops redirecting immediately
+// @Tags         links
+// @Param        short_url path string true "Short URL alias"
+// @Success      204
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Param        X-Admin-Token header string false "Admin token, required for links without an owner"
+// @Security     BearerAuth
+// @Router       /links/{short_url} [delete]
+func (h *handler) DeleteLink(c *router.Context) {
+	code := c.Param("short_url")
+
+	if err := h.usecase.DeleteLink(c.Request.Context(), code); err != nil {
+		h.linkError(c, err, "failed to delete link")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// linkError отвечает на ошибку операций управления ссылкой.
+func (h *handler) linkError(c *router.Context, err error, msg string) {
+	if errors.Is(err, domain.ErrNotFound) {
+		c.JSON(http.StatusNotFound, router.H{"error": domain.ErrNotFound.Error()})
+		return
+	}
+	if errors.Is(err, domain.ErrForbidden) {
+		c.JSON(http.StatusForbidden, router.H{"error": domain.ErrForbidden.Error()})
+		return
+	}
+	// Изменение, которое вместе с текущими полями дает неверную ссылку.
+	if errors.Is(err, domain.ErrInvalidUpdate) || errors.Is(err, domain.ErrInvalidExpiration) ||
+		errors.Is(err, domain.ErrInvalidActivation) || errors.Is(err, domain.ErrPasswordTooLong) {
+		c.JSON(http.StatusUnprocessableEntity, router.H{"error": err.Error()})
+		return
+	}
+
+	h.log.Error().Err(err).Str("code", c.Param("short_url")).Msg(msg)
+	c.JSON(http.StatusInternalServerError, router.H{"error": "db error"})
+}
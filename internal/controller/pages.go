@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"embed"
+	"html/template"
+
+	"github.com/adexcell/shortener/pkg/router"
+)
+
+//go:embed templates/*.html
+var templates embed.FS
+
+// pages - HTML-страницы, которые сервис показывает вместо редиректа.
+var pages = template.Must(template.ParseFS(templates, "templates/*.html"))
+
+// renderPage отдает HTML-страницу name. Страницы зависят от ссылки и
+// состояния посетителя, поэтому не кэшируются.
+func (h *handler) renderPage(c *router.Context, status int, name string, data any) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+
+	if err := pages.ExecuteTemplate(c.Writer, name, data); err != nil {
+		h.log.Error().Err(err).Str("page", name).Msg("failed to render page")
+	}
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/router"
+)
+
+// passwordFormField - поле формы с паролем защищенной ссылки.
+const passwordFormField = "password"
+
+// renderPasswordForm показывает форму ввода пароля вместо редиректа.
+// message - текст ошибки предыдущей попытки или "".
+func (h *handler) renderPasswordForm(c *router.Context, status int, message string) {
+	h.renderPage(c, status, "password.html", struct{ Error string }{message})
+}
+
+// passwordError показывает форму заново, если переход не прошел проверку
+// пароля. Возвращает false для остальных ошибок.
+func (h *handler) passwordError(c *router.Context, err error) bool {
+	switch {
+	case errors.Is(err, domain.ErrPasswordRequired):
+		h.renderPasswordForm(c, http.StatusUnauthorized, "")
+	case errors.Is(err, domain.ErrWrongPassword):
+		h.renderPasswordForm(c, http.StatusUnauthorized, "Неверный пароль")
+	case errors.Is(err, domain.ErrTooManyAttempts):
+		h.renderPasswordForm(c, http.StatusTooManyRequests, "Слишком много попыток, попробуйте позже")
+	default:
+		return false
+	}
+	return true
+}
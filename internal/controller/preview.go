@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/router"
+)
+
+// previewSuffix в конце кода (/s/abc+) открывает предпросмотр ссылки. В
+// алиасах символ "+" запрещен, поэтому с кодом он не путается.
+const previewSuffix = "+"
+
+// previewQuery - query-параметр (?preview=1), открывающий предпросмотр.
+const previewQuery = "preview"
+
+// previewCode возвращает код ссылки и признак запроса предпросмотра.
+func previewCode(c *router.Context) (string, bool) {
+	code, preview := strings.CutSuffix(c.Param("short_url"), previewSuffix)
+	return code, preview || c.Query(previewQuery) == "1"
+}
+
+// previewPage - данные страницы предпросмотра.
+type previewPage struct {
+	Title     string
+	URL       string
+	Link      string
+	CreatedAt time.Time
+	Protected bool
+	Varies    bool
+}
+
+// preview показывает адрес, подпись и дату создания ссылки вместо
+// редиректа. Клик не засчитывается; адрес защищенной ссылки не раскрывается.
+func (h *handler) preview(c *router.Context, code string) {
+	link, err := h.usecase.Preview(c.Request.Context(), code)
+	if err != nil {
+		h.visitError(c, code, err)
+		return
+	}
+
+	page := previewPage{
+		Title:     link.Title,
+		Link:      "/s/" + link.ShortCode,
+		CreatedAt: link.CreatedAt,
+		Protected: link.Protected(),
+		Varies:    link.Personalized(),
+	}
+	if !page.Protected {
+		page.URL = link.LongURL
+	}
+
+	h.renderPage(c, http.StatusOK, "preview.html", page)
+}
+
+// interstitialPage - данные страницы перехода с обратным отсчетом.
+type interstitialPage struct {
+	Title   string
+	URL     string
+	Seconds int
+}
+
+// renderInterstitial показывает адрес перехода и перенаправляет на него
+// через redirect.Interstitial секунд.
+func (h *handler) renderInterstitial(c *router.Context, redirect domain.Redirect) {
+	h.renderPage(c, http.StatusOK, "interstitial.html", interstitialPage{
+		Title:   redirect.Title,
+		URL:     redirect.URL,
+		Seconds: redirect.Interstitial,
+	})
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/qr"
+	"github.com/adexcell/shortener/pkg/router"
+)
+
+const qrURL = "/qr/:short_url"
+
+// sourceQuery - служебный query-параметр с каналом перехода (?_src=qr). В
+// целевой URL он не пробрасывается.
+const sourceQuery = "_src"
+
+// qrMaxAge - сколько клиентам разрешено кэшировать QR-код. Изображение
+// зависит только от кода и параметров, поэтому кэшируется надолго.
+const qrMaxAge = 24 * 60 * 60
+
+var qrContentTypes = map[string]string{
+	qr.PNG: "image/png",
+	qr.SVG: "image/svg+xml",
+}
+
+// visitSource извлекает канал перехода из query и убирает служебный
+// параметр. Неизвестные каналы игнорируются, чтобы не засорять аналитику.
+func visitSource(query url.Values) (url.Values, string) {
+	source := query.Get(sourceQuery)
+	query.Del(sourceQuery)
+	if source != domain.SourceQR {
+		source = ""
+	}
+	return query, source
+}
+
+// qrOptions собирает параметры изображения из query поверх значений по
+// умолчанию.
+func qrOptions(c *router.Context) (qr.Options, error) {
+	opts := qr.DefaultOptions()
+	opts.Format = c.DefaultQuery("format", opts.Format)
+	opts.Level = strings.ToUpper(c.DefaultQuery("level", opts.Level))
+
+	var err error
+	if v := c.Query("size"); v != "" {
+		if opts.Size, err = strconv.Atoi(v); err != nil {
+			return qr.Options{}, qr.ErrInvalidSize
+		}
+	}
+	if v := c.Query("margin"); v != "" {
+		if opts.Margin, err = strconv.Atoi(v); err != nil {
+			return qr.Options{}, qr.ErrInvalidMargin
+		}
+	}
+	if v := c.Query("fg"); v != "" {
+		if opts.Foreground, err = qr.ParseColor(v); err != nil {
+			return qr.Options{}, err
+		}
+	}
+	if v := c.Query("bg"); v != "" {
+		if opts.Background, err = qr.ParseColor(v); err != nil {
+			return qr.Options{}, err
+		}
+	}
+
+	return opts, opts.Validate()
+}
+
+// shortURL возвращает полный адрес короткой ссылки с меткой перехода по QR.
+func (h *handler) shortURL(c *router.Context, code string) string {
+	base := h.cfg.BaseURL
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+
+	return strings.TrimRight(base, "/") + "/s/" + url.PathEscape(code) + "?" + sourceQuery + "=" + domain.SourceQR
+}
+
+// qrCacheControl разрешает общим кэшам хранить QR-код, только если адрес
+// в нем построен от BaseURL. Без него адрес зависит от Host и
+// X-Forwarded-Proto запроса, и подделанный заголовок отравил бы общий кэш
+// кодом, ведущим на чужой хост.
+func (h *handler) qrCacheControl() string {
+	if h.cfg.BaseURL == "" {
+		return fmt.Sprintf("private, max-age=%d", qrMaxAge)
+	}
+	return fmt.Sprintf("public, max-age=%d", qrMaxAge)
+}
+
+// GetQR godoc
+// @Summary      QR code of a short URL
+// @Description  Render a QR code with the full short URL in PNG or SVG. Scans are marked with _src=qr and counted in by_source.
+// @Description  A link that exists but does not redirect yet or anymore still gets a code, so it can be printed before active_from.
+// @Tags         shortener
+// @Produce      png
+// @Produce      image/svg+xml
+// @Param        short_url path string true "Short URL alias"
+// @Param        format query string false "png (default) or svg"
+// @Param        size query int false "Image side in pixels, 64-2048 (default 256)"
+// @Param        margin query int false "Quiet zone in modules, 0-16 (default 4)"
+// @Param        level query string false "Error correction level: L, M (default), Q or H"
+// @Param        fg query string false "Foreground color, hex RRGGBB (default 000000)"
+// @Param        bg query string false "Background color, hex RRGGBB (default ffffff)"
+// @Param        If-None-Match header string false "ETag of a cached image"
+// @Success      200  {file}    binary
+// @Success      304  {string}  string "Not modified"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /qr/{short_url} [get]
+func (h *handler) GetQR(c *router.Context) {
+	code := c.Param("short_url")
+
+	opts, err := qrOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, router.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.usecase.Preview(c.Request.Context(), code); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			c.JSON(http.StatusNotFound, router.H{"error": domain.ErrNotFound.Error()})
+			return
+		case !unavailable(err):
+			h.log.Error().Err(err).Str("code", code).Msg("failed to get link for qr code")
+			c.JSON(http.StatusInternalServerError, router.H{"error": "internal error"})
+			return
+		}
+	}
+
+	content := h.shortURL(c, code)
+	etag := qrETag(content, opts)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", h.qrCacheControl())
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	img, err := qr.Encode(content, opts)
+	if err != nil {
+		if errors.Is(err, qr.ErrSizeTooSmall) {
+			c.JSON(http.StatusBadRequest, router.H{"error": err.Error()})
+			return
+		}
+		h.log.Error().Err(err).Str("code", code).Msg("failed to render qr code")
+		c.JSON(http.StatusInternalServerError, router.H{"error": "internal error"})
+		return
+	}
+
+	c.Data(http.StatusOK, qrContentTypes[opts.Format], img)
+}
+
+// qrETag - отпечаток изображения: оно однозначно задается содержимым кода
+// и параметрами отрисовки.
+func qrETag(content string, o qr.Options) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s|%s|%d|%d|%s|%v|%v",
+		content, o.Format, o.Size, o.Margin, o.Level, o.Foreground, o.Background))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
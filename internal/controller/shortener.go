@@ -2,8 +2,16 @@
 package controller
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/adexcell/shortener/internal/domain"
 	"github.com/adexcell/shortener/pkg/log"
@@ -13,22 +21,56 @@ import (
 const (
 	postShortURL  = "/shorten"
 	conversionURL = "/s/:short_url"
-	analyticsURL  = "/analytics/:short_url"
+	// conversionPathURL - переход с хвостом пути для ссылок с path_passthrough
+	conversionPathURL = "/s/:short_url/*path"
+	analyticsURL      = "/analytics/:short_url"
+	linkURL           = "/links/:short_url"
+
+	idempotencyKeyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 255
 )
 
 type handler struct {
 	usecase domain.ShortenerUsecase
+	cfg     Config
+	// aliases - правила пользовательских алиасов новых ссылок
+	aliases domain.AliasPolicy
 	log     log.Log
+	// notFoundPage - содержимое cfg.NotFoundPage, читается один раз при старте
+	notFoundPage []byte
 }
 
-func NewShortenHandler(u domain.ShortenerUsecase, l log.Log) router.Handler {
-	return &handler{usecase: u, log: l}
+func NewShortenHandler(u domain.ShortenerUsecase, cfg Config, aliases domain.AliasPolicy, l log.Log) router.Handler {
+	h := &handler{usecase: u, cfg: cfg, aliases: aliases, log: l}
+
+	if cfg.NotFoundPage != "" {
+		page, err := os.ReadFile(cfg.NotFoundPage)
+		if err != nil {
+			l.Warn().Err(err).Str("path", cfg.NotFoundPage).Msg("404 page unavailable, falling back to json")
+		}
+		h.notFoundPage = page
+	}
+
+	return h
 }
 
 func (h *handler) Register(router *router.Router) {
 	router.POST(postShortURL, h.PostShortURL)
+	router.POST(postShortURLBatch, h.PostShortURLBatch)
 	router.GET(conversionURL, h.ConversionURL)
+	router.GET(conversionPathURL, h.ConversionURL)
+	// HEAD - проверка ссылки сервисами превью и утилитами, клик помечается
+	// как клик бота
+	router.HEAD(conversionURL, h.ConversionURL)
+	router.HEAD(conversionPathURL, h.ConversionURL)
+	// POST - отправка формы пароля защищенной ссылки
+	router.POST(conversionURL, h.ConversionURL)
+	router.POST(conversionPathURL, h.ConversionURL)
+	router.GET(qrURL, h.GetQR)
 	router.GET(analyticsURL, h.GetAnalytics)
+	router.GET(linkURL, h.GetLink)
+	router.PATCH(linkURL, h.UpdateLink)
+	router.DELETE(linkURL, h.DeleteLink)
 }
 
 type shortenRequest struct {
@@ -36,6 +78,106 @@ type shortenRequest struct {
 	URL string `json:"url" binding:"required"`
 	// кастомное имя сокращенной ссылки
 	Alias string `json:"alias"`
+	// ActiveFrom - момент, с которого ссылка начинает редиректить (RFC 3339)
+	ActiveFrom *time.Time `json:"active_from"`
+	// ExpiresAt - момент, после которого ссылка перестает работать (RFC 3339)
+	ExpiresAt *time.Time `json:"expires_at"`
+	// ActiveUntil - синоним expires_at
+	ActiveUntil *time.Time `json:"active_until"`
+	// TTL - время жизни ссылки от момента создания, например "72h"
+	TTL string `json:"ttl"`
+	// RedirectStatus - код редиректа: 301, 302 (по умолчанию), 307 или 308
+	RedirectStatus int `json:"redirect_status"`
+	// QueryMode - проброс query-параметров перехода: none (по умолчанию),
+	// keep (при совпадении ключей побеждает сохраненный URL), override
+	// (побеждает переход) или append (значения объединяются)
+	QueryMode domain.QueryMode `json:"query_mode" enums:"none,keep,override,append"`
+	// PathPassthrough - дописывать хвост пути (/s/{code}/extra/path) к целевому URL
+	PathPassthrough bool `json:"path_passthrough"`
+	// UTM - метки, которые добавляются к целевому URL при переходе
+	UTM *utmDTO `json:"utm"`
+	// Targets - правила таргетинга по платформе и устройству, проверяются
+	// по порядку; если ни одно не подошло, переход идет на url
+	Targets []targetRuleDTO `json:"targets"`
+	// Variants - варианты ротации с весами: переход, не попавший под
+	// правила таргетинга, уходит на вариант, выбранный пропорционально весу
+	Variants []variantDTO `json:"variants"`
+	// StickyVariants - закреплять выбранный вариант за посетителем cookie
+	StickyVariants bool `json:"sticky_variants"`
+	// Password - пароль, который нужно ввести перед переходом (от 4 символов, не больше 72 байт в UTF-8)
+	Password string `json:"password"`
+	// Title - подпись для страниц предпросмотра и перехода
+	Title string `json:"title"`
+	// InterstitialSeconds - показывать страницу с адресом и обратным
+	// отсчетом (до 30 секунд) перед редиректом
+	InterstitialSeconds int `json:"interstitial_seconds"`
+	// MaxClicks - сколько переходов разрешено по ссылке, например 1 для
+	// одноразовой; 0 - без ограничения
+	MaxClicks int `json:"max_clicks"`
+}
+
+// options собирает настройки ссылки из запроса.
+func (r shortenRequest) options() (domain.LinkOptions, error) {
+	expiresAt, err := r.expiration()
+	if err != nil {
+		return domain.LinkOptions{}, err
+	}
+
+	return domain.LinkOptions{
+		ActiveFrom:          r.ActiveFrom,
+		ExpiresAt:           expiresAt,
+		RedirectStatus:      r.RedirectStatus,
+		QueryMode:           r.QueryMode,
+		PathPassthrough:     r.PathPassthrough,
+		UTM:                 utmToDomain(r.UTM),
+		Targets:             targetsToDomain(r.Targets),
+		Variants:            variantsToDomain(r.Variants),
+		StickyVariants:      r.StickyVariants,
+		Password:            r.Password,
+		Title:               r.Title,
+		InterstitialSeconds: r.InterstitialSeconds,
+		MaxClicks:           r.MaxClicks,
+	}, nil
+}
+
+// expiration возвращает момент истечения ссылки из expires_at, active_until
+// или ttl.
+func (r shortenRequest) expiration() (*time.Time, error) {
+	if r.ExpiresAt != nil && r.ActiveUntil != nil {
+		return nil, errors.New("only one of expires_at or active_until can be set")
+	}
+	expiresAt := cmp.Or(r.ExpiresAt, r.ActiveUntil)
+
+	if r.TTL == "" {
+		return expiresAt, nil
+	}
+	if expiresAt != nil {
+		return nil, errors.New("only one of expires_at or ttl can be set")
+	}
+
+	ttl, err := time.ParseDuration(r.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ttl: %w", err)
+	}
+
+	deadline := time.Now().Add(ttl)
+	return &deadline, nil
+}
+
+// fingerprint - отпечаток запроса для проверки Idempotency-Key. Считается
+// по телу запроса, а не по ссылке, так как ttl дает разный expires_at при повторе.
+// Сам пароль в отпечаток не входит, только то, что он задан: отпечаток
+// сутки лежит в Redis, и по быстрому SHA-256 пароль подбирался бы офлайн.
+// Повтор с другим паролем поэтому не считается другим запросом.
+func (r shortenRequest) fingerprint() string {
+	protected := r.Password != ""
+	r.Password = ""
+	b, _ := json.Marshal(struct {
+		shortenRequest
+		Protected bool `json:"protected"`
+	}{r, protected})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
 
 // PostShortURL godoc
@@ -45,10 +187,13 @@ type shortenRequest struct {
 // @Accept       json
 // @Produce      json
 // @Param        input body shortenRequest true "URL to shorten"
+// @Param        Idempotency-Key header string false "Replay the first response for repeated requests with this key"
 // @Success      200  {object}  map[string]string
 // @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
 // @Failure      422  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Security     BearerAuth
 // @Router       /shorten [post]
 func (h *handler) PostShortURL(c *router.Context) {
 	var req shortenRequest
@@ -57,16 +202,39 @@ func (h *handler) PostShortURL(c *router.Context) {
 		return
 	}
 
-	dto, err := shortenerToControllerDTO(req.Alias, req.URL)
+	opts, err := req.options()
 	if err != nil {
 		c.JSON(http.StatusUnprocessableEntity, router.H{"error": err.Error()})
 		return
 	}
 
-	code, err := h.usecase.Shorten(c.Request.Context(), dto.ShortCode, dto.LongURL)
+	dto, err := shortenerToControllerDTO(h.aliases, req.Alias, req.URL, opts)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, router.H{"error": err.Error()})
+		return
+	}
+
+	idem := domain.Idempotency{
+		Key:         c.GetHeader(idempotencyKeyHeader),
+		Fingerprint: req.fingerprint(),
+	}
+	if len(idem.Key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, router.H{"error": "idempotency key is too long"})
+		return
+	}
+
+	code, err := h.usecase.Shorten(c.Request.Context(), *shortenerToDomain(*dto), idem)
 	if err != nil {
 		if errors.Is(err, domain.ErrAlreadyExists) {
-			c.JSON(http.StatusConflict, router.H{"error": domain.ErrAlreadyExists})
+			c.JSON(http.StatusConflict, router.H{"error": domain.ErrAlreadyExists.Error()})
+			return
+		}
+		if errors.Is(err, domain.ErrIdempotencyInProgress) {
+			c.JSON(http.StatusConflict, router.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, domain.ErrIdempotencyMismatch) || errors.Is(err, domain.ErrPasswordTooLong) {
+			c.JSON(http.StatusUnprocessableEntity, router.H{"error": err.Error()})
 			return
 		}
 		h.log.Error().Err(err).Msg("failed to shorten url")
@@ -79,15 +247,41 @@ func (h *handler) PostShortURL(c *router.Context) {
 
 // ConversionURL godoc
 // @Summary      Redirect to original URL
-// @Description  Redirect user to the original long URL based on the short alias
+// @Description  Redirect user to the original long URL based on the short alias.
+// @Description  Query parameters and a trailing path (/s/{short_url}/extra/path) are passed to the destination if the link allows it.
+// @Description  A password protected link renders a password form instead; the form is posted back to the same address.
+// @Description  A short_url ending with "+" or ?preview=1 renders a preview page with the destination without counting a click,
+// @Description  and links with interstitial_seconds render a countdown page before redirecting.
+// @Description  Clicks from known bots, link unfurlers and HEAD requests are marked as bot clicks in analytics
 // @Tags         shortener
+// @Accept       x-www-form-urlencoded
 // @Produce      html
 // @Param        short_url path string true "Short URL alias"
-// @Success      302  {string}  string "Redirect to original URL"
-// @Failure      404  {object}  map[string]string
+// @Param        password formData string false "Password of a protected link (POST only)"
+// @Param        preview query string false "1 - show the preview page instead of redirecting"
+// @Success      301  {string}  string "Permanent redirect to original URL"
+// @Success      302  {string}  string "Redirect to original URL, or to the configured fallback URL for an unknown or dead code"
+// @Success      307  {string}  string "Temporary redirect preserving the method"
+// @Success      308  {string}  string "Permanent redirect preserving the method"
+// @Success      200  {string}  string "Preview or interstitial page"
+// @Success      303  {string}  string "Redirect after a correct password"
+// @Success      204  {string}  string "Bot or HEAD request to a link with max_clicks: no Location, no click consumed"
+// @Failure      401  {string}  string "Password form"
+// @Failure      404  {string}  string "Not found page"
+// @Failure      410  {object}  map[string]string "Link expired or its max_clicks limit is reached"
+// @Failure      403  {string}  string "Link is not active yet (active_from)"
+// @Failure      429  {string}  string "Too many password attempts"
+// @Failure      500  {object}  map[string]string
 // @Router       /s/{short_url} [get]
+// @Router       /s/{short_url} [head]
+// @Router       /s/{short_url} [post]
 func (h *handler) ConversionURL(c *router.Context) {
-	code := c.Param("short_url")
+	code, preview := previewCode(c)
+	if preview && c.Request.Method != http.MethodPost {
+		h.preview(c, code)
+		return
+	}
+
 	ip := c.ClientIP()
 	userAgent := c.GetHeader("User-Agent")
 	dto, err := statsToControllerDTO(code, ip, userAgent)
@@ -96,40 +290,193 @@ func (h *handler) ConversionURL(c *router.Context) {
 		return
 	}
 
-	longURL, err := h.usecase.GetOriginal(
-		c.Request.Context(),
-		dto.ShortCode,
-		dto.IP,
-		dto.UserAgent,
-	)
+	query, source := visitSource(c.Request.URL.Query())
+	redirect, err := h.usecase.GetOriginal(c.Request.Context(), domain.Visit{
+		ShortCode: dto.ShortCode,
+		IP:        dto.IP,
+		UserAgent: dto.UserAgent,
+		Path:      c.Param("path"),
+		Query:     query,
+		Source:    source,
+		Referrer:  c.GetHeader("Referer"),
+		Method:    c.Request.Method,
+		Variant:   stickyVariant(c, dto.ShortCode),
+		Password:  c.PostForm(passwordFormField),
+	})
 	if err != nil {
+		if h.passwordError(c, err) {
+			return
+		}
+		h.visitError(c, dto.ShortCode, err)
+		return
+	}
+	if redirect.Withheld {
+		// Ссылка с лимитом существует, но боту ее адрес не отдается.
+		c.Header("Cache-Control", "no-store")
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	h.log.Info().Str("longURL", redirect.URL).Int("status", redirect.StatusCode).Msg("redirect")
+	if redirect.StickyVariant {
+		setStickyVariant(c, dto.ShortCode, redirect.Variant)
+	}
+	if c.Request.Method == http.MethodPost {
+		// После формы пароля браузер должен перейти по адресу GET-запросом:
+		// 307 и 308 отправили бы пароль на целевой сайт.
+		redirect.StatusCode = http.StatusSeeOther
+	}
+	if redirect.Interstitial > 0 {
+		h.renderInterstitial(c, redirect)
+		return
+	}
+	c.Header("Cache-Control", redirectCacheControl(redirect, time.Now()))
+	c.Redirect(redirect.StatusCode, redirect.URL)
+}
+
+// visitError отвечает на переход или предпросмотр недоступной ссылки.
+// Несуществующие и неработающие коды уходят на адрес-заглушку, если он
+// настроен; ошибки хранилищ не выдаются за 404.
+func (h *handler) visitError(c *router.Context, code string, err error) {
+	if errors.Is(err, domain.ErrNotYetActive) && h.cfg.NotYetActive != notYetActiveNotFound {
+		h.renderPage(c, http.StatusForbidden, "not_yet_active.html", nil)
+		return
+	}
+
+	if !unavailable(err) {
+		h.log.Error().Err(err).Str("code", code).Msg("failed to resolve short url")
+		c.JSON(http.StatusInternalServerError, router.H{"error": "internal error"})
+		return
+	}
+
+	if url, ok := h.usecase.Fallback(c.Request.Context(), code); ok {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrExpired):
+		c.JSON(http.StatusGone, router.H{"error": domain.ErrExpired.Error()})
+	case errors.Is(err, domain.ErrClicksExhausted):
+		c.JSON(http.StatusGone, router.H{"error": domain.ErrClicksExhausted.Error()})
+	default:
+		h.notFound(c)
+	}
+}
+
+// unavailable сообщает, что ссылка не существует или сейчас не работает -
+// в отличие от сбоя хранилища.
+func unavailable(err error) bool {
+	return errors.Is(err, domain.ErrNotFound) ||
+		errors.Is(err, domain.ErrDisabled) ||
+		errors.Is(err, domain.ErrExpired) ||
+		errors.Is(err, domain.ErrClicksExhausted) ||
+		errors.Is(err, domain.ErrNotYetActive)
+}
+
+// notFound отвечает 404 страницей cfg.NotFoundPage или JSON, если ее нет.
+func (h *handler) notFound(c *router.Context) {
+	if h.notFoundPage == nil {
 		c.JSON(http.StatusNotFound, router.H{"error": "not found"})
 		return
 	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusNotFound, "text/html; charset=utf-8", h.notFoundPage)
+}
+
+// permanentRedirectMaxAge - сколько клиентам разрешено кэшировать
+// постоянный редирект. Без ограничения браузеры помнят 301 бессрочно, и
+// смена URL или отключение ссылки до них бы не дошли.
+const permanentRedirectMaxAge = 24 * time.Hour
+
+// stickyVariantMaxAge - время жизни cookie с закрепленным вариантом ротации.
+const stickyVariantMaxAge = 30 * 24 * time.Hour
 
-	h.log.Info().Str("longURL", longURL).Msg("redirect")
-	c.Redirect(http.StatusFound, longURL)
+// stickyVariantCookie - имя cookie с вариантом ротации ссылки.
+func stickyVariantCookie(code string) string {
+	return "v_" + code
+}
+
+// stickyVariant возвращает вариант, закрепленный за посетителем, или "".
+func stickyVariant(c *router.Context, code string) string {
+	value, err := c.Cookie(stickyVariantCookie(code))
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// setStickyVariant закрепляет вариант за посетителем. Cookie ограничена
+// путем ссылки, чтобы не уходить с переходами по другим кодам.
+func setStickyVariant(c *router.Context, code, variant string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stickyVariantCookie(code), variant, int(stickyVariantMaxAge.Seconds()),
+		"/s/"+code, "", c.Request.TLS != nil, true)
+}
+
+// redirectCacheControl возвращает Cache-Control для редиректа. Постоянные
+// редиректы кэшируются, но не дольше срока жизни ссылки; временные не
+// кэшируются, чтобы каждый клик доходил до сервиса и попадал в аналитику.
+// Редирект, зависящий от посетителя (таргетинг, ротация), и редирект ссылки
+// с лимитом кликов не кэшируются никогда.
+func redirectCacheControl(r domain.Redirect, now time.Time) string {
+	if !r.Permanent() || r.Varies {
+		return "private, no-cache, no-store, must-revalidate"
+	}
+
+	maxAge := permanentRedirectMaxAge
+	if r.ExpiresAt != nil {
+		maxAge = min(maxAge, r.ExpiresAt.Sub(now))
+	}
+	if maxAge <= 0 {
+		return "no-store"
+	}
+
+	return fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
 }
 
 // GetAnalytics godoc
 // @Summary      Get URL Analytics
-// @Description  Get click statistics for a short URL
+// @Description  Get click statistics for a short URL over a period, with a zero-filled time series of clicks.
+// @Description  Bot clicks are excluded from totals and breakdowns unless include_bots is set; bot_clicks and by_bot always count them
 // @Tags         analytics
 // @Produce      json
 // @Param        short_url path string true "Short URL alias"
+// @Param        from query string false "Period start, RFC 3339 or date (2006-01-02) in tz; default - the last 24 hours, 7 days, 13 weeks or 12 months by granularity"
+// @Param        to query string false "Period end (exclusive), RFC 3339 or date in tz; default - now"
+// @Param        granularity query string false "Series step: hour, day (default), week or month"
+// @Param        tz query string false "IANA time zone of the buckets, e.g. Europe/Moscow (default UTC)"
+// @Param        include_bots query bool false "Count bot clicks in totals and breakdowns (default false)"
 // @Success      200  {object}  statsControllerDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Param        X-Admin-Token header string false "Admin token, required for links without an owner"
+// @Security     BearerAuth
 // @Router       /analytics/{short_url} [get]
 func (h *handler) GetAnalytics(c *router.Context) {
 	code := c.Param("short_url")
 
+	q, err := domain.NewStatsQuery(c.Query("from"), c.Query("to"), c.Query("granularity"), c.Query("tz"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, router.H{"error": err.Error()})
+		return
+	}
+	if v := c.Query("include_bots"); v != "" {
+		if q.IncludeBots, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, router.H{"error": "include_bots must be a boolean"})
+			return
+		}
+	}
+
 	var stats domain.Stats
-	stats, err := h.usecase.GetStats(c.Request.Context(), code)
+	stats, err = h.usecase.GetStats(c.Request.Context(), code, q)
 	if err != nil {
-		h.log.Error().Err(err).Msg("failed to get count shorten url")
-		c.JSON(http.StatusInternalServerError, router.H{"error": "db error"})
+		h.linkError(c, err, "failed to get count shorten url")
 		return
 	}
 
-	c.JSON(http.StatusOK, statsToResponse(stats))
+	c.JSON(http.StatusOK, statsToResponse(stats, q))
 }
@@ -1,39 +1,200 @@
 package controller
 
 import (
+	"cmp"
+	"strings"
 	"time"
 
 	"github.com/adexcell/shortener/internal/domain"
 )
 
 type shortenerControllerDTO struct {
-	ID        string    `json:"id"`
-	ShortCode string    `json:"short_code"`
-	LongURL   string    `json:"long_url"`
+	ID              string           `json:"id"`
+	ShortCode       string           `json:"short_code"`
+	LongURL         string           `json:"long_url"`
+	ActiveFrom      *time.Time       `json:"active_from,omitempty"`
+	ExpiresAt       *time.Time       `json:"expires_at,omitempty"`
+	RedirectStatus  int              `json:"redirect_status"`
+	QueryMode       domain.QueryMode `json:"query_mode"`
+	PathPassthrough bool             `json:"path_passthrough"`
+	UTM             *utmDTO          `json:"utm,omitempty"`
+	Targets         []targetRuleDTO  `json:"targets,omitempty"`
+	Variants        []variantDTO     `json:"variants,omitempty"`
+	StickyVariants  bool             `json:"sticky_variants"`
+	// Password - пароль из запроса; в ответах не возвращается
+	Password            string `json:"-"`
+	PasswordProtected   bool   `json:"password_protected"`
+	Title               string `json:"title,omitempty"`
+	InterstitialSeconds int    `json:"interstitial_seconds"`
+	MaxClicks           int    `json:"max_clicks"`
+	// Clicks - сколько переходов из max_clicks уже израсходовано
+	Clicks    int       `json:"clicks"`
+	Disabled  bool      `json:"disabled"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
-func shortenerToControllerDTO(shortCode, longURL string) (*shortenerControllerDTO, error) {
-	s, err := domain.NewShortener(shortCode, longURL)
+// utmDTO - шаблон UTM-меток, добавляемых к целевому URL при переходе.
+type utmDTO struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+func utmToResponse(u domain.UTM) *utmDTO {
+	if u.IsZero() {
+		return nil
+	}
+	dto := utmDTO(u)
+	return &dto
+}
+
+// targetRuleDTO - правило таргетинга: переход с подходящей платформы,
+// устройства или из подходящей страны уходит на URL правила.
+type targetRuleDTO struct {
+	// Platform - ios, android, windows, macos, linux или chromeos
+	Platform string `json:"platform,omitempty" enums:"ios,android,windows,macos,linux,chromeos"`
+	// Device - mobile, tablet или desktop
+	Device string `json:"device,omitempty" enums:"mobile,tablet,desktop"`
+	// Country - ISO 3166-1 alpha-2 код страны, например "DE"
+	Country string `json:"country,omitempty"`
+	URL     string `json:"url"`
+}
+
+func targetsToDomain(dto []targetRuleDTO) []domain.TargetRule {
+	if len(dto) == 0 {
+		return nil
+	}
+
+	rules := make([]domain.TargetRule, len(dto))
+	for i, r := range dto {
+		r.Country = strings.ToUpper(r.Country)
+		rules[i] = domain.TargetRule(r)
+	}
+	return rules
+}
+
+func targetsToResponse(rules []domain.TargetRule) []targetRuleDTO {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	dto := make([]targetRuleDTO, len(rules))
+	for i, r := range rules {
+		dto[i] = targetRuleDTO(r)
+	}
+	return dto
+}
+
+// variantDTO - вариант ротации: переходы распределяются между вариантами
+// пропорционально весам.
+type variantDTO struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+func variantsToDomain(dto []variantDTO) []domain.Variant {
+	if len(dto) == 0 {
+		return nil
+	}
+
+	variants := make([]domain.Variant, len(dto))
+	for i, v := range dto {
+		variants[i] = domain.Variant(v)
+	}
+	return variants
+}
+
+func variantsToResponse(variants []domain.Variant) []variantDTO {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	dto := make([]variantDTO, len(variants))
+	for i, v := range variants {
+		dto[i] = variantDTO(v)
+	}
+	return dto
+}
+
+func shortenerToControllerDTO(aliases domain.AliasPolicy, shortCode, longURL string, opts domain.LinkOptions) (*shortenerControllerDTO, error) {
+	s, err := domain.NewShortener(aliases, shortCode, longURL, opts)
 	if err != nil {
 		return &shortenerControllerDTO{}, err
 	}
 
 	res := &shortenerControllerDTO{
-		ID:        s.ID,
-		ShortCode: s.ShortCode,
-		LongURL:   s.LongURL,
+		ID:                  s.ID,
+		ShortCode:           s.ShortCode,
+		LongURL:             s.LongURL,
+		ActiveFrom:          s.ActiveFrom,
+		ExpiresAt:           s.ExpiresAt,
+		RedirectStatus:      s.RedirectStatus,
+		QueryMode:           s.QueryMode,
+		PathPassthrough:     s.PathPassthrough,
+		UTM:                 utmToResponse(s.UTM),
+		Targets:             targetsToResponse(s.Targets),
+		Variants:            variantsToResponse(s.Variants),
+		StickyVariants:      s.StickyVariants,
+		Password:            s.Password,
+		Title:               s.Title,
+		InterstitialSeconds: s.InterstitialSeconds,
+		MaxClicks:           s.MaxClicks,
 	}
 	return res, nil
 }
 
 func shortenerToDomain(dto shortenerControllerDTO) *domain.Shortener {
 	return &domain.Shortener{
-		ID:        dto.ID,
-		ShortCode: dto.ShortCode,
-		LongURL:   dto.LongURL,
-		CreatedAt: dto.CreatedAt,
+		ID:                  dto.ID,
+		ShortCode:           dto.ShortCode,
+		LongURL:             dto.LongURL,
+		ActiveFrom:          dto.ActiveFrom,
+		ExpiresAt:           dto.ExpiresAt,
+		RedirectStatus:      dto.RedirectStatus,
+		QueryMode:           dto.QueryMode,
+		PathPassthrough:     dto.PathPassthrough,
+		UTM:                 utmToDomain(dto.UTM),
+		Targets:             targetsToDomain(dto.Targets),
+		Variants:            variantsToDomain(dto.Variants),
+		StickyVariants:      dto.StickyVariants,
+		Password:            dto.Password,
+		Title:               dto.Title,
+		InterstitialSeconds: dto.InterstitialSeconds,
+		MaxClicks:           dto.MaxClicks,
+		CreatedAt:           dto.CreatedAt,
 	}
 }
 
+func utmToDomain(dto *utmDTO) domain.UTM {
+	if dto == nil {
+		return domain.UTM{}
+	}
+	return domain.UTM(*dto)
+}
 
+func shortenerToResponse(s domain.Shortener) shortenerControllerDTO {
+	return shortenerControllerDTO{
+		ID:                  s.ID,
+		ShortCode:           s.ShortCode,
+		LongURL:             s.LongURL,
+		ActiveFrom:          s.ActiveFrom,
+		ExpiresAt:           s.ExpiresAt,
+		RedirectStatus:      s.Redirect().StatusCode,
+		QueryMode:           cmp.Or(s.QueryMode, domain.QueryDrop),
+		PathPassthrough:     s.PathPassthrough,
+		UTM:                 utmToResponse(s.UTM),
+		Targets:             targetsToResponse(s.Targets),
+		Variants:            variantsToResponse(s.Variants),
+		StickyVariants:      s.StickyVariants,
+		PasswordProtected:   s.Protected(),
+		Title:               s.Title,
+		InterstitialSeconds: s.InterstitialSeconds,
+		MaxClicks:           s.MaxClicks,
+		Clicks:              s.Clicks,
+		Disabled:            s.Disabled,
+		CreatedAt:           s.CreatedAt,
+	}
+}
@@ -3,10 +3,16 @@ package controller_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/adexcell/shortener/internal/controller"
 	"github.com/adexcell/shortener/internal/domain"
@@ -22,31 +28,75 @@ type MockUsecase struct {
 	mock.Mock
 }
 
-func (m *MockUsecase) Shorten(ctx context.Context, shortCode, longURL string) (string, error) {
-	args := m.Called(ctx, shortCode, longURL)
+func (m *MockUsecase) Shorten(ctx context.Context, s domain.Shortener, idem domain.Idempotency) (string, error) {
+	args := m.Called(ctx, s, idem)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockUsecase) GetOriginal(ctx context.Context, shortCode, ip, userAgent string) (string, error) {
-	args := m.Called(ctx, shortCode, ip, userAgent)
-	return args.String(0), args.Error(1)
+func (m *MockUsecase) ShortenBatch(ctx context.Context, links []domain.Shortener) ([]domain.BatchResult, error) {
+	args := m.Called(ctx, links)
+	results, _ := args.Get(0).([]domain.BatchResult)
+	return results, args.Error(1)
+}
+
+func (m *MockUsecase) GetOriginal(ctx context.Context, v domain.Visit) (domain.Redirect, error) {
+	args := m.Called(ctx, v)
+	return args.Get(0).(domain.Redirect), args.Error(1)
+}
+
+func (m *MockUsecase) Preview(ctx context.Context, shortCode string) (domain.Shortener, error) {
+	args := m.Called(ctx, shortCode)
+	return args.Get(0).(domain.Shortener), args.Error(1)
 }
 
-func (m *MockUsecase) GetStats(ctx context.Context, shortCode string) (domain.Stats, error) {
+func (m *MockUsecase) Fallback(ctx context.Context, shortCode string) (string, bool) {
 	args := m.Called(ctx, shortCode)
+	return args.String(0), args.Bool(1)
+}
+
+func (m *MockUsecase) GetStats(ctx context.Context, shortCode string, q domain.StatsQuery) (domain.Stats, error) {
+	args := m.Called(ctx, shortCode, q)
 	return args.Get(0).(domain.Stats), args.Error(1)
 }
 
+func (m *MockUsecase) GetLink(ctx context.Context, shortCode string) (domain.Shortener, error) {
+	args := m.Called(ctx, shortCode)
+	return args.Get(0).(domain.Shortener), args.Error(1)
+}
+
+func (m *MockUsecase) UpdateLink(ctx context.Context, shortCode string, upd domain.LinkUpdate) (domain.Shortener, error) {
+	args := m.Called(ctx, shortCode, upd)
+	return args.Get(0).(domain.Shortener), args.Error(1)
+}
+
+func (m *MockUsecase) DeleteLink(ctx context.Context, shortCode string) error {
+	args := m.Called(ctx, shortCode)
+	return args.Error(0)
+}
+
 func (m *MockUsecase) Close() error {
 	// TODO:
 	return nil
 }
 
+// visitTo сопоставляет переход по коду shortCode.
+func visitTo(shortCode string) any {
+	return mock.MatchedBy(func(v domain.Visit) bool { return v.ShortCode == shortCode })
+}
+
 // --- Setup ---
 
+// aliases - правила алиасов по умолчанию: встроенный список запрещенных,
+// регистр сохраняется.
+var aliases = domain.NewAliasPolicy(false, nil)
+
 func setupRouter() *router.Router {
 	// Use test mode to suppress debug logs
-	return router.New(router.Config{GinMode: "test"})
+	r, err := router.New(router.Config{GinMode: "test"})
+	if err != nil {
+		panic(err)
+	}
+	return r
 }
 
 // --- Tests ---
@@ -57,14 +107,16 @@ func TestHandler_PostShortURL(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockUC := new(MockUsecase)
 		r := setupRouter()
-		h := controller.NewShortenHandler(mockUC, log)
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
 		h.Register(r)
 
 		inputBody := `{"url": "https://example.com"}`
 		expectedCode := "abcdef"
 
 		// Expectation
-		mockUC.On("Shorten", mock.Anything, "", "https://example.com").Return(expectedCode, nil)
+		mockUC.On("Shorten", mock.Anything, mock.MatchedBy(func(s domain.Shortener) bool {
+			return s.ShortCode == "" && s.LongURL == "https://example.com" && s.ExpiresAt == nil
+		}), mock.Anything).Return(expectedCode, nil)
 
 		// Request
 		w := httptest.NewRecorder()
@@ -80,7 +132,7 @@ func TestHandler_PostShortURL(t *testing.T) {
 	t.Run("invalid json", func(t *testing.T) {
 		mockUC := new(MockUsecase)
 		r := setupRouter()
-		h := controller.NewShortenHandler(mockUC, log)
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
 		h.Register(r)
 
 		// Request (empty body)
@@ -96,13 +148,15 @@ func TestHandler_PostShortURL(t *testing.T) {
 	t.Run("internal error", func(t *testing.T) {
 		mockUC := new(MockUsecase)
 		r := setupRouter()
-		h := controller.NewShortenHandler(mockUC, log)
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
 		h.Register(r)
 
 		inputBody := `{"url": "https://example.com", "alias": "custom"}`
 
 		// Expectation
-		mockUC.On("Shorten", mock.Anything, "custom", "https://example.com").Return("", errors.New("db fail"))
+		mockUC.On("Shorten", mock.Anything, mock.MatchedBy(func(s domain.Shortener) bool {
+			return s.ShortCode == "custom" && s.LongURL == "https://example.com"
+		}), mock.Anything).Return("", errors.New("db fail"))
 
 		// Request
 		w := httptest.NewRecorder()
@@ -113,6 +167,397 @@ func TestHandler_PostShortURL(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		mockUC.AssertExpectations(t)
 	})
+
+	t.Run("invalid alias", func(t *testing.T) {
+		cases := map[string]string{
+			"reserved":    `{"url": "https://example.com", "alias": "Static"}`,
+			"with slash":  `{"url": "https://example.com", "alias": "a/b/c"}`,
+			"unicode":     `{"url": "https://example.com", "alias": "ссылка"}`,
+			"too long":    `{"url": "https://example.com", "alias": "abcdefghijk"}`,
+			"too short":   `{"url": "https://example.com", "alias": "ab"}`,
+			"with spaces": `{"url": "https://example.com", "alias": "my link"}`,
+		}
+
+		for name, inputBody := range cases {
+			t.Run(name, func(t *testing.T) {
+				mockUC := new(MockUsecase)
+				r := setupRouter()
+				h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+				h.Register(r)
+
+				// Request
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(inputBody))
+				r.ServeHTTP(w, req)
+
+				// Assertions
+				assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+				assert.Contains(t, w.Body.String(), "alias")
+				mockUC.AssertNotCalled(t, "Shorten")
+			})
+		}
+	})
+
+	t.Run("password too long", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		// 40 кириллических символов - 80 байт, больше предела bcrypt.
+		inputBody := `{"url": "https://example.com", "password": "` + strings.Repeat("п", 40) + `"}`
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(inputBody))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), domain.ErrPasswordTooLong.Error())
+		mockUC.AssertNotCalled(t, "Shorten")
+	})
+
+	t.Run("alias taken", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		inputBody := `{"url": "https://example.com", "alias": "taken"}`
+
+		// Expectation
+		mockUC.On("Shorten", mock.Anything, mock.Anything, mock.Anything).Return("", domain.ErrAlreadyExists)
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(inputBody))
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), domain.ErrAlreadyExists.Error())
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		inputBody := `{"url": "https://example.com", "ttl": "1h"}`
+
+		// Expectation: the same body gives the same fingerprint on retry
+		var fingerprints []string
+		mockUC.On("Shorten", mock.Anything, mock.Anything, mock.MatchedBy(func(idem domain.Idempotency) bool {
+			return idem.Key == "retry-1"
+		})).Run(func(args mock.Arguments) {
+			fingerprints = append(fingerprints, args.Get(2).(domain.Idempotency).Fingerprint)
+		}).Return("abcdef", nil).Twice()
+
+		for range 2 {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(inputBody))
+			req.Header.Set("Idempotency-Key", "retry-1")
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		// Assertions
+		assert.Len(t, fingerprints, 2)
+		assert.Equal(t, fingerprints[0], fingerprints[1])
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("idempotency fingerprint without password", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		var fingerprints []string
+		mockUC.On("Shorten", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			fingerprints = append(fingerprints, args.Get(2).(domain.Idempotency).Fingerprint)
+		}).Return("abcdef", nil).Times(3)
+
+		for _, body := range []string{
+			`{"url": "https://example.com", "password": "hunter2"}`,
+			`{"url": "https://example.com", "password": "letmein"}`,
+			`{"url": "https://example.com"}`,
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+			req.Header.Set("Idempotency-Key", "retry-1")
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		// Пароль не влияет на отпечаток, а наличие защиты - влияет.
+		if assert.Len(t, fingerprints, 3) {
+			assert.Equal(t, fingerprints[0], fingerprints[1])
+			assert.NotEqual(t, fingerprints[0], fingerprints[2])
+		}
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("idempotency key reused with another body", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		// Expectation
+		mockUC.On("Shorten", mock.Anything, mock.Anything, mock.Anything).Return("", domain.ErrIdempotencyMismatch)
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url": "https://example.org"}`))
+		req.Header.Set("Idempotency-Key", "retry-1")
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("with ttl", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		inputBody := `{"url": "https://example.com", "ttl": "1h"}`
+
+		// Expectation
+		mockUC.On("Shorten", mock.Anything, mock.MatchedBy(func(s domain.Shortener) bool {
+			return s.ExpiresAt != nil && time.Until(*s.ExpiresAt) > 59*time.Minute
+		}), mock.Anything).Return("abcdef", nil)
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(inputBody))
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("expiration in the past", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		inputBody := `{"url": "https://example.com", "expires_at": "2000-01-01T00:00:00Z"}`
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(inputBody))
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		mockUC.AssertNotCalled(t, "Shorten")
+	})
+
+	t.Run("activation window", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		inputBody := `{"url": "https://example.com", "active_from": "2099-01-01T09:00:00Z", "active_until": "2099-02-01T00:00:00Z"}`
+
+		mockUC.On("Shorten", mock.Anything, mock.MatchedBy(func(s domain.Shortener) bool {
+			return s.ActiveFrom != nil && s.ActiveFrom.Equal(time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)) &&
+				s.ExpiresAt != nil && s.ExpiresAt.Equal(time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC))
+		}), mock.Anything).Return("embargo", nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(inputBody))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("activation after expiration", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		inputBody := `{"url": "https://example.com", "active_from": "2099-03-01T00:00:00Z", "expires_at": "2099-02-01T00:00:00Z"}`
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(inputBody))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		mockUC.AssertNotCalled(t, "Shorten")
+	})
+
+	t.Run("redirect status", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("Shorten", mock.Anything, mock.MatchedBy(func(s domain.Shortener) bool {
+			return s.RedirectStatus == http.StatusMovedPermanently
+		}), mock.Anything).Return("abcdef", nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url": "https://example.com", "redirect_status": 301}`))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("country rule", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("Shorten", mock.Anything, mock.MatchedBy(func(s domain.Shortener) bool {
+			return len(s.Targets) == 1 && s.Targets[0].Country == "DE"
+		}), mock.Anything).Return("abcdef", nil)
+
+		body := `{"url": "https://example.com", "targets": [{"country": "de", "url": "https://example.de"}]}`
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("rule without conditions", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		body := `{"url": "https://example.com", "targets": [{"url": "https://example.de"}]}`
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		mockUC.AssertNotCalled(t, "Shorten")
+	})
+
+	t.Run("unsupported redirect status", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url": "https://example.com", "redirect_status": 303}`))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		mockUC.AssertNotCalled(t, "Shorten")
+	})
+}
+
+func TestHandler_PostShortURLBatch(t *testing.T) {
+	log := log.New()
+
+	t.Run("per item statuses", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		inputBody := `[
+			{"url": "https://a.example", "alias": "promo"},
+			{"url": "not a url"},
+			{"url": "https://b.example", "alias": "taken"},
+			{"url": "https://c.example"}
+		]`
+
+		mockUC.On("ShortenBatch", mock.Anything, mock.MatchedBy(func(links []domain.Shortener) bool {
+			return len(links) == 3 && links[0].ShortCode == "promo" && links[2].ShortCode == ""
+		})).Return([]domain.BatchResult{
+			{ShortCode: "promo"},
+			{Err: domain.ErrAlreadyExists},
+			{ShortCode: "xyz123"},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten/batch", bytes.NewBufferString(inputBody))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Results []struct {
+				ShortURL string `json:"short_url"`
+				Status   string `json:"status"`
+			} `json:"results"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		if assert.Len(t, resp.Results, 4) {
+			assert.Equal(t, "created", resp.Results[0].Status)
+			assert.Equal(t, "promo", resp.Results[0].ShortURL)
+			assert.Equal(t, "invalid", resp.Results[1].Status)
+			assert.Equal(t, "conflict", resp.Results[2].Status)
+			assert.Equal(t, "created", resp.Results[3].Status)
+			assert.Equal(t, "xyz123", resp.Results[3].ShortURL)
+		}
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten/batch", bytes.NewBufferString("[]"))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockUC.AssertNotCalled(t, "ShortenBatch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("ShortenBatch", mock.Anything, mock.Anything).Return(nil, errors.New("db fail"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten/batch", bytes.NewBufferString(`[{"url": "https://a.example"}]`))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("too many password protected items", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		items := make([]string, 21)
+		for i := range items {
+			items[i] = `{"url": "https://a.example", "password": "hunter2"}`
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/shorten/batch", bytes.NewBufferString("["+strings.Join(items, ",")+"]"))
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockUC.AssertNotCalled(t, "ShortenBatch", mock.Anything, mock.Anything)
+	})
 }
 
 func TestHandler_ConversionURL(t *testing.T) {
@@ -121,14 +566,14 @@ func TestHandler_ConversionURL(t *testing.T) {
 	t.Run("redirect success", func(t *testing.T) {
 		mockUC := new(MockUsecase)
 		r := setupRouter()
-		h := controller.NewShortenHandler(mockUC, log)
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
 		h.Register(r)
 
 		shortCode := "abc1234"
 		longURL := "https://google.com"
 
 		// Expectation
-		mockUC.On("GetOriginal", mock.Anything, shortCode, mock.Anything, mock.Anything).Return(longURL, nil)
+		mockUC.On("GetOriginal", mock.Anything, visitTo(shortCode)).Return(domain.Redirect{URL: longURL, StatusCode: http.StatusFound}, nil)
 
 		// Request
 		w := httptest.NewRecorder()
@@ -141,29 +586,858 @@ func TestHandler_ConversionURL(t *testing.T) {
 		// Assertions
 		assert.Equal(t, http.StatusFound, w.Code) // 302
 		assert.Equal(t, longURL, w.Header().Get("Location"))
+		assert.Contains(t, w.Header().Get("Cache-Control"), "no-store")
 		mockUC.AssertExpectations(t)
 	})
 
-	t.Run("not found", func(t *testing.T) {
+	t.Run("qr scan with referrer", func(t *testing.T) {
 		mockUC := new(MockUsecase)
 		r := setupRouter()
-		h := controller.NewShortenHandler(mockUC, log)
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
 		h.Register(r)
 
-		shortCode := "missing"
+		scan := mock.MatchedBy(func(v domain.Visit) bool {
+			return v.Source == domain.SourceQR && !v.Query.Has("_src") && v.Query.Get("ref") == "poster" &&
+				v.Referrer == "https://news.example/article"
+		})
+		mockUC.On("GetOriginal", mock.Anything, scan).Return(domain.Redirect{URL: "https://google.com", StatusCode: http.StatusFound}, nil)
 
-		// Expectation
-		mockUC.On("GetOriginal", mock.Anything, shortCode, mock.Anything, mock.Anything).Return("", errors.New("not found"))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/abc1234?_src=qr&ref=poster", nil)
+		req.Header.Set("Referer", "https://news.example/article")
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("head request", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		head := mock.MatchedBy(func(v domain.Visit) bool {
+			return v.ShortCode == "abc1234" && v.Method == http.MethodHead
+		})
+		mockUC.On("GetOriginal", mock.Anything, head).Return(domain.Redirect{URL: "https://google.com", StatusCode: http.StatusFound}, nil)
 
-		// Request
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", "/s/"+shortCode, nil)
+		req, _ := http.NewRequest("HEAD", "/s/abc1234", nil)
 		req.RemoteAddr = "127.0.0.1:12345"
 
 		r.ServeHTTP(w, req)
 
-		// Assertions
-		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://google.com", w.Header().Get("Location"))
 		mockUC.AssertExpectations(t)
 	})
+
+	t.Run("forwarded for is not trusted by default", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		fromConn := mock.MatchedBy(func(v domain.Visit) bool { return v.IP == "127.0.0.1" })
+		mockUC.On("GetOriginal", mock.Anything, fromConn).Return(domain.Redirect{URL: "https://google.com", StatusCode: http.StatusFound}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/abc1234", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("head request to limited link", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, mock.Anything).Return(domain.Redirect{Varies: true, Withheld: true}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("HEAD", "/s/invite", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("permanent redirect", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		expiresAt := time.Now().Add(time.Hour)
+		mockUC.On("GetOriginal", mock.Anything, visitTo("seo")).
+			Return(domain.Redirect{URL: "https://google.com", StatusCode: http.StatusPermanentRedirect, ExpiresAt: &expiresAt}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/seo", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+		cacheControl := w.Header().Get("Cache-Control")
+		assert.True(t, strings.HasPrefix(cacheControl, "public, max-age="), cacheControl)
+		maxAge, err := strconv.Atoi(strings.TrimPrefix(cacheControl, "public, max-age="))
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, maxAge, 3600)
+	})
+
+	t.Run("path and query passed to usecase", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, mock.MatchedBy(func(v domain.Visit) bool {
+			return v.ShortCode == "aff" && v.Path == "/extra/path" && v.Query.Get("utm_source") == "mail"
+		})).Return(domain.Redirect{URL: "https://shop.example/extra/path?utm_source=mail", StatusCode: http.StatusFound}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/aff/extra/path?utm_source=mail", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://shop.example/extra/path?utm_source=mail", w.Header().Get("Location"))
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("sticky variant cookie", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, mock.MatchedBy(func(v domain.Visit) bool {
+			return v.ShortCode == "ab" && v.Variant == "spring sale"
+		})).Return(domain.Redirect{
+			URL:           "https://landing.example/b",
+			StatusCode:    http.StatusMovedPermanently,
+			Varies:        true,
+			Variant:       "spring sale",
+			StickyVariant: true,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/ab", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		req.AddCookie(&http.Cookie{Name: "v_ab", Value: "spring+sale"})
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Contains(t, w.Header().Get("Cache-Control"), "no-store")
+		cookie := w.Result().Cookies()
+		if assert.Len(t, cookie, 1) {
+			assert.Equal(t, "v_ab", cookie[0].Name)
+			assert.Equal(t, "spring+sale", cookie[0].Value)
+			assert.Equal(t, "/s/ab", cookie[0].Path)
+			assert.True(t, cookie[0].HttpOnly)
+		}
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("password form", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, visitTo("docs")).Return(domain.Redirect{}, domain.ErrPasswordRequired)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/docs", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), `name="password"`)
+		assert.Empty(t, w.Header().Get("Location"))
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("password submitted", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, mock.MatchedBy(func(v domain.Visit) bool {
+			return v.ShortCode == "docs" && v.Password == "s3cret" && v.Query.Get("page") == "2"
+		})).Return(domain.Redirect{URL: "https://docs.example?page=2", StatusCode: http.StatusPermanentRedirect, Varies: true}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/s/docs?page=2", strings.NewReader("password=s3cret"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		// 303, а не 308: пароль не должен уйти на целевой сайт.
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "https://docs.example?page=2", w.Header().Get("Location"))
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("too many attempts", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, visitTo("docs")).Return(domain.Redirect{}, domain.ErrTooManyAttempts)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/s/docs", strings.NewReader("password=guess"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Contains(t, w.Body.String(), `name="password"`)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("preview", func(t *testing.T) {
+		created := time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+		links := map[string]domain.Shortener{
+			"docs":   {ShortCode: "docs", LongURL: "https://docs.example/q3", Title: "Q3 report", CreatedAt: created},
+			"secret": {ShortCode: "secret", LongURL: "https://docs.example/hr", PasswordHash: "hash", CreatedAt: created},
+		}
+
+		for _, path := range []string{"/s/docs+", "/s/docs?preview=1", "/s/secret+"} {
+			mockUC := new(MockUsecase)
+			r := setupRouter()
+			h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+			h.Register(r)
+
+			code := strings.TrimSuffix(strings.TrimPrefix(strings.Split(path, "?")[0], "/s/"), "+")
+			link := links[code]
+			mockUC.On("Preview", mock.Anything, code).Return(link, nil).Once()
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			req.RemoteAddr = "127.0.0.1:12345"
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, path)
+			assert.Contains(t, w.Body.String(), "14.03.2026", path)
+			assert.Contains(t, w.Body.String(), `href="/s/`+code+`"`, path)
+			if link.PasswordHash != "" {
+				assert.NotContains(t, w.Body.String(), link.LongURL, path)
+			} else {
+				assert.Contains(t, w.Body.String(), link.LongURL, path)
+				assert.Contains(t, w.Body.String(), link.Title, path)
+			}
+			// Предпросмотр не переход: GetOriginal не вызывается, клик не засчитывается.
+			mockUC.AssertNotCalled(t, "GetOriginal", mock.Anything, mock.Anything)
+			mockUC.AssertExpectations(t)
+		}
+	})
+
+	t.Run("interstitial", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, visitTo("slow")).
+			Return(domain.Redirect{URL: "https://partner.example", StatusCode: http.StatusFound, Interstitial: 5}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/slow", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Contains(t, w.Body.String(), `content="5;url=https://partner.example"`)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		shortCode := "missing"
+
+		// Expectation
+		mockUC.On("GetOriginal", mock.Anything, visitTo(shortCode)).Return(domain.Redirect{}, domain.ErrNotFound)
+		mockUC.On("Fallback", mock.Anything, shortCode).Return("", false)
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/"+shortCode, nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("not found page", func(t *testing.T) {
+		page := filepath.Join(t.TempDir(), "404.html")
+		if err := os.WriteFile(page, []byte("<h2>Ссылка не найдена</h2>"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{NotFoundPage: page}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, visitTo("missing")).Return(domain.Redirect{}, domain.ErrDisabled)
+		mockUC.On("Fallback", mock.Anything, "missing").Return("", false)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/missing", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), "Ссылка не найдена")
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("fallback url", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, visitTo("gone")).Return(domain.Redirect{}, domain.ErrExpired)
+		mockUC.On("Fallback", mock.Anything, "gone").Return("https://home.example", true)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/gone", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://home.example", w.Header().Get("Location"))
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, visitTo("abc1234")).Return(domain.Redirect{}, errors.New("connection refused"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/abc1234", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		// Сбой хранилища - не 404 и не повод уводить на заглушку.
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mockUC.AssertNotCalled(t, "Fallback", mock.Anything, mock.Anything)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("click limit reached", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("GetOriginal", mock.Anything, visitTo("invite")).Return(domain.Redirect{}, domain.ErrClicksExhausted)
+		mockUC.On("Fallback", mock.Anything, "invite").Return("", false)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/invite", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGone, w.Code)
+		assert.Contains(t, w.Body.String(), domain.ErrClicksExhausted.Error())
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("not active yet", func(t *testing.T) {
+		for cfg, want := range map[controller.Config]int{
+			{}:                          http.StatusForbidden,
+			{NotYetActive: "not_found"}: http.StatusNotFound,
+		} {
+			mockUC := new(MockUsecase)
+			r := setupRouter()
+			h := controller.NewShortenHandler(mockUC, cfg, aliases, log)
+			h.Register(r)
+
+			mockUC.On("GetOriginal", mock.Anything, visitTo("press")).Return(domain.Redirect{}, domain.ErrNotYetActive)
+			mockUC.On("Fallback", mock.Anything, "press").Return("", false).Maybe()
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/s/press", nil)
+			req.RemoteAddr = "127.0.0.1:12345"
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, want, w.Code, cfg.NotYetActive)
+			assert.Empty(t, w.Header().Get("Location"))
+			mockUC.AssertExpectations(t)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		shortCode := "expired"
+
+		// Expectation
+		mockUC.On("GetOriginal", mock.Anything, visitTo(shortCode)).Return(domain.Redirect{}, domain.ErrExpired)
+		mockUC.On("Fallback", mock.Anything, shortCode).Return("", false)
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/s/"+shortCode, nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusGone, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+}
+
+func TestHandler_Links(t *testing.T) {
+	log := log.New()
+
+	t.Run("get not found", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		// Expectation
+		mockUC.On("GetLink", mock.Anything, "missing").Return(domain.Shortener{}, domain.ErrNotFound)
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/links/missing", nil)
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("update target", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		newURL := "https://example.org"
+
+		// Expectation
+		mockUC.On("UpdateLink", mock.Anything, "abcdef", mock.MatchedBy(func(upd domain.LinkUpdate) bool {
+			return upd.LongURL != nil && *upd.LongURL == newURL && upd.Disabled == nil
+		})).Return(domain.Shortener{ShortCode: "abcdef", LongURL: newURL}, nil)
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/links/abcdef", bytes.NewBufferString(`{"url": "https://example.org"}`))
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), newURL)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("anonymous update and delete are forbidden", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		r.Use(controller.Auth(new(MockAPIKeyUsecase), "secret", log))
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		anonymous := mock.MatchedBy(func(ctx context.Context) bool {
+			return domain.OwnerFromContext(ctx) == "" && !domain.IsAdmin(ctx)
+		})
+		mockUC.On("UpdateLink", anonymous, "abcdef", mock.Anything).Return(domain.Shortener{}, domain.ErrForbidden)
+		mockUC.On("DeleteLink", anonymous, "abcdef").Return(domain.ErrForbidden)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/links/abcdef", bytes.NewBufferString(`{"url": "https://phishing.example"}`))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("DELETE", "/links/abcdef", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("admin deletes ownerless link", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		r.Use(controller.Auth(new(MockAPIKeyUsecase), "secret", log))
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		admin := mock.MatchedBy(func(ctx context.Context) bool { return domain.IsAdmin(ctx) })
+		mockUC.On("DeleteLink", admin, "abcdef").Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/links/abcdef", nil)
+		req.Header.Set("X-Admin-Token", "secret")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("update activation window", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		// null снимает эмбарго, отсутствующее поле не меняется.
+		clear := mock.MatchedBy(func(upd domain.LinkUpdate) bool {
+			return upd.ActiveFrom != nil && upd.ActiveFrom.Time == nil && upd.ExpiresAt == nil
+		})
+		mockUC.On("UpdateLink", mock.Anything, "abcdef", clear).Return(domain.Shortener{ShortCode: "abcdef"}, nil).Once()
+		until := mock.MatchedBy(func(upd domain.LinkUpdate) bool {
+			return upd.ActiveFrom == nil && upd.ExpiresAt != nil && upd.ExpiresAt.Time != nil &&
+				upd.ExpiresAt.Time.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+		})
+		mockUC.On("UpdateLink", mock.Anything, "abcdef", until).Return(domain.Shortener{}, domain.ErrInvalidActivation).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/links/abcdef", bytes.NewBufferString(`{"active_from": null}`))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("PATCH", "/links/abcdef", bytes.NewBufferString(`{"active_until": "2030-01-01T00:00:00Z"}`))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("PATCH", "/links/abcdef", bytes.NewBufferString(`{"expires_at": null, "active_until": null}`))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("update password", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		unprotect := mock.MatchedBy(func(upd domain.LinkUpdate) bool { return upd.Password != nil && *upd.Password == "" })
+		mockUC.On("UpdateLink", mock.Anything, "abcdef", unprotect).Return(domain.Shortener{ShortCode: "abcdef"}, nil).Once()
+
+		for body, want := range map[string]int{
+			`{"password": ""}`:    http.StatusOK,
+			`{"password": "abc"}`: http.StatusUnprocessableEntity,
+			// 40 кириллических символов - 80 байт, больше предела bcrypt.
+			`{"password": "` + strings.Repeat("п", 40) + `"}`: http.StatusUnprocessableEntity,
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PATCH", "/links/abcdef", bytes.NewBufferString(body))
+			r.ServeHTTP(w, req)
+			assert.Equal(t, want, w.Code, body)
+		}
+
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("update with empty body", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/links/abcdef", bytes.NewBufferString(`{}`))
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		mockUC.AssertNotCalled(t, "UpdateLink")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		// Expectation
+		mockUC.On("DeleteLink", mock.Anything, "abcdef").Return(nil)
+
+		// Request
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/links/abcdef", nil)
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+}
+
+func TestHandler_GetQR(t *testing.T) {
+	log := log.New()
+	link := domain.Shortener{ShortCode: "poster", LongURL: "https://example.com"}
+
+	t.Run("png", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{BaseURL: "https://sho.rt"}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("Preview", mock.Anything, "poster").Return(link, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/qr/poster?size=128&fg=1a2b3c", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("\x89PNG")))
+		assert.Equal(t, "public, max-age=86400", w.Header().Get("Cache-Control"))
+		etag := w.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+
+		// Повторный запрос с тем же ETag не перерисовывает изображение.
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/qr/poster?size=128&fg=1a2b3c", nil)
+		req.Header.Set("If-None-Match", etag)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.Bytes())
+
+		// Другие параметры - другое изображение.
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/qr/poster?size=128", nil)
+		req.Header.Set("If-None-Match", etag)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("svg of a link not active yet", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("Preview", mock.Anything, "poster").Return(domain.Shortener{}, domain.ErrNotYetActive)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/qr/poster?format=svg&bg=%23ffeedd", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `fill="#ffeedd"`)
+		// Без base_url адрес в коде зависит от заголовков запроса.
+		assert.Equal(t, "private, max-age=86400", w.Header().Get("Cache-Control"))
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		for _, query := range []string{"format=gif", "size=abc", "size=10", "margin=99", "level=X", "fg=red"} {
+			mockUC := new(MockUsecase)
+			r := setupRouter()
+			h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+			h.Register(r)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/qr/poster?"+query, nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockUC.AssertNotCalled(t, "Preview", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		mockUC.On("Preview", mock.Anything, "missing").Return(domain.Shortener{}, domain.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/qr/missing", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockUC.AssertExpectations(t)
+	})
+}
+
+func TestHandler_GetAnalytics(t *testing.T) {
+	log := log.New()
+
+	t.Run("default period", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		// Без from - последние 7 календарных дней по UTC, включая текущий,
+		// без кликов ботов.
+		lastWeek := mock.MatchedBy(func(q domain.StatsQuery) bool {
+			series := q.Series()
+			return q.Granularity == domain.GranularityDay && q.Location == time.UTC && !q.IncludeBots &&
+				len(series) == 7 && series[0].Hour() == 0 && series[6].Before(q.To)
+		})
+		stats := domain.Stats{TotalClicks: 3, DailyUniqueVisitors: 2, Live: &domain.LiveVisitors{Today: 1, DailyTotal: 4}}
+		mockUC.On("GetStats", mock.Anything, "promo", lastWeek).Return(stats, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/analytics/promo", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"daily_unique_visitors":2`)
+		assert.Contains(t, w.Body.String(), `"live_visitors":{"today":1,"daily_total":4}`)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("include bots", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		withBots := mock.MatchedBy(func(q domain.StatsQuery) bool { return q.IncludeBots })
+		stats := domain.Stats{TotalClicks: 5, BotClicks: 3, ByBot: map[string]int{"slack": 2, "head": 1}}
+		mockUC.On("GetStats", mock.Anything, "promo", withBots).Return(stats, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/analytics/promo?include_bots=true", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"bot_clicks":3`)
+		assert.Contains(t, w.Body.String(), `"include_bots":true`)
+		assert.Contains(t, w.Body.String(), `"by_bot":{"head":1,"slack":2}`)
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("range in time zone", func(t *testing.T) {
+		mockUC := new(MockUsecase)
+		r := setupRouter()
+		h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+		h.Register(r)
+
+		moscow, err := time.LoadLocation("Europe/Moscow")
+		if err != nil {
+			t.Skip("tzdata is not available")
+		}
+		from := time.Date(2026, 3, 2, 0, 0, 0, 0, moscow)
+		weeks := mock.MatchedBy(func(q domain.StatsQuery) bool {
+			return q.From.Equal(from) && q.To.Equal(from.AddDate(0, 0, 21)) &&
+				q.Granularity == domain.GranularityWeek && len(q.Series()) == 3
+		})
+		series := []domain.StatsPoint{
+			{Time: from, Clicks: 4},
+			{Time: from.AddDate(0, 0, 7)},
+			{Time: from.AddDate(0, 0, 14), Clicks: 1},
+		}
+		mockUC.On("GetStats", mock.Anything, "promo", weeks).Return(domain.Stats{TotalClicks: 5, Series: series}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/analytics/promo?from=2026-03-02&to=2026-03-23&granularity=week&tz=Europe/Moscow", nil)
+		r.ServeHTTP(w, req)
+
+		var resp struct {
+			Granularity string `json:"granularity"`
+			TZ          string `json:"tz"`
+			Series      []struct {
+				Time   string `json:"time"`
+				Clicks int    `json:"clicks"`
+			} `json:"series"`
+		}
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "week", resp.Granularity)
+		assert.Equal(t, "Europe/Moscow", resp.TZ)
+		if assert.Len(t, resp.Series, 3) {
+			assert.Equal(t, "2026-03-02T00:00:00+03:00", resp.Series[0].Time)
+			assert.Equal(t, 4, resp.Series[0].Clicks)
+			assert.Equal(t, 0, resp.Series[1].Clicks)
+		}
+		mockUC.AssertExpectations(t)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		queries := []string{
+			"granularity=minute",
+			"tz=Mars/Olympus",
+			"from=yesterday",
+			"from=2026-03-10&to=2026-03-01",
+			"from=2020-01-01&to=2026-01-01&granularity=hour",
+			"include_bots=maybe",
+		}
+		for _, query := range queries {
+			mockUC := new(MockUsecase)
+			r := setupRouter()
+			h := controller.NewShortenHandler(mockUC, controller.Config{}, aliases, log)
+			h.Register(r)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/analytics/promo?"+query, nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockUC.AssertNotCalled(t, "GetStats", mock.Anything, mock.Anything, mock.Anything)
+		}
+	})
 }
@@ -7,14 +7,29 @@ import (
 )
 
 type statsControllerDTO struct {
-	ID          string         `json:"id"`
-	ShortCode   string         `json:"short_code"`
-	IP          string         `json:"ip"`
-	UserAgent   string         `json:"user_agent"`
-	TotalClicks int            `json:"total_clicks"`
-	ByDate      map[string]int `json:"by_date"`
-	ByBrowser   map[string]int `json:"by_browser"`
-	ClickedAt   time.Time      `json:"clicked_at"`
+	ID                  string           `json:"id"`
+	ShortCode           string           `json:"short_code"`
+	IP                  string           `json:"ip"`
+	UserAgent           string           `json:"user_agent"`
+	TotalClicks         int              `json:"total_clicks"`
+	BotClicks           int              `json:"bot_clicks"`
+	IncludeBots         bool             `json:"include_bots"`
+	DailyUniqueVisitors int              `json:"daily_unique_visitors"`
+	LiveVisitors        *liveVisitorsDTO `json:"live_visitors,omitempty"`
+	From                time.Time        `json:"from"`
+	To                  time.Time        `json:"to"`
+	Granularity         string           `json:"granularity" enums:"hour,day,week,month"`
+	TZ                  string           `json:"tz"`
+	Series              []statsPoint     `json:"series"`
+	ByBrowser           map[string]int   `json:"by_browser"`
+	ByCampaign          map[string]int   `json:"by_campaign"`
+	ByVariant           map[string]int   `json:"by_variant"`
+	BySource            map[string]int   `json:"by_source"`
+	ByOS                map[string]int   `json:"by_os"`
+	ByDevice            map[string]int   `json:"by_device"`
+	ByReferrer          map[string]int   `json:"by_referrer"`
+	ByBot               map[string]int   `json:"by_bot"`
+	ClickedAt           time.Time        `json:"clicked_at"`
 }
 
 func statsToControllerDTO(shortCode, ip, userAgent string) (*statsControllerDTO, error) {
@@ -29,22 +44,60 @@ func statsToControllerDTO(shortCode, ip, userAgent string) (*statsControllerDTO,
 		IP:          s.IP,
 		UserAgent:   s.UserAgent,
 		TotalClicks: s.TotalClicks,
-		ByDate:      s.ByDate,
 		ByBrowser:   s.ByBrowser,
 		ClickedAt:   s.ClickedAt,
 	}
 	return res, nil
 }
 
-func statsToResponse(s domain.Stats) statsControllerDTO {
+// statsPoint - клики и уникальные посетители за интервал, начинающийся в Time.
+// Для недели и месяца Unique - сумма уникальных посетителей по суткам.
+type statsPoint struct {
+	Time   time.Time `json:"time"`
+	Clicks int       `json:"clicks"`
+	Unique int       `json:"unique"`
+}
+
+// liveVisitorsDTO - оценка уникальных посетителей в реальном времени.
+type liveVisitorsDTO struct {
+	Today      int `json:"today"`
+	DailyTotal int `json:"daily_total"`
+}
+
+func statsToResponse(s domain.Stats, q domain.StatsQuery) statsControllerDTO {
+	series := make([]statsPoint, len(s.Series))
+	for i, p := range s.Series {
+		series[i] = statsPoint(p)
+	}
+
+	var live *liveVisitorsDTO
+	if s.Live != nil {
+		live = &liveVisitorsDTO{Today: s.Live.Today, DailyTotal: s.Live.DailyTotal}
+	}
+
 	return statsControllerDTO{
-		ID:          s.ID,
-		ShortCode:   s.ShortCode,
-		IP:          s.IP,
-		UserAgent:   s.UserAgent,
-		TotalClicks: s.TotalClicks,
-		ByDate:      s.ByDate,
-		ByBrowser:   s.ByBrowser,
-		ClickedAt:   s.ClickedAt,
+		ID:                  s.ID,
+		ShortCode:           s.ShortCode,
+		IP:                  s.IP,
+		UserAgent:           s.UserAgent,
+		TotalClicks:         s.TotalClicks,
+		BotClicks:           s.BotClicks,
+		IncludeBots:         q.IncludeBots,
+		DailyUniqueVisitors: s.DailyUniqueVisitors,
+		LiveVisitors:        live,
+		From:                q.From,
+		To:                  q.To,
+		Granularity:         q.Granularity,
+		TZ:                  q.Location.String(),
+		Series:              series,
+		ByBrowser:           s.ByBrowser,
+		ByCampaign:          s.ByCampaign,
+		ByVariant:           s.ByVariant,
+		BySource:            s.BySource,
+		ByOS:                s.ByOS,
+		ByDevice:            s.ByDevice,
+		ByReferrer:          s.ByReferrer,
+		ByBot:               s.ByBot,
+		ClickedAt:           s.ClickedAt,
 	}
 }
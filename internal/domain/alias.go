@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Пределы длины алиаса. Верхний совпадает со схемой urls.short_code.
+const (
+	MinAliasLength = 3
+	MaxAliasLength = MaxShortCodeLength
+)
+
+// DefaultReservedAliases - алиасы, занятые маршрутами сервиса или
+// недопустимые по смыслу. Список из конфига добавляется к ним.
+var DefaultReservedAliases = []string{
+	"admin", "analytics", "api", "links", "qr", "s", "shorten", "static", "swagger",
+	"fuck", "shit", "bitch", "cunt", "dick", "porn", "sex", "nazi",
+}
+
+var aliasRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// AliasPolicy - правила для пользовательских алиасов.
+type AliasPolicy struct {
+	// Lowercase приводит алиас к нижнему регистру перед сохранением.
+	Lowercase bool
+	// Reserved - запрещенные алиасы, сравниваются без учета регистра.
+	Reserved []string
+}
+
+// NewAliasPolicy возвращает правила алиасов, в которых reserved дополняет
+// DefaultReservedAliases.
+func NewAliasPolicy(lowercase bool, reserved []string) AliasPolicy {
+	return AliasPolicy{
+		Lowercase: lowercase,
+		Reserved:  slices.Concat(DefaultReservedAliases, reserved),
+	}
+}
+
+// Normalize проверяет пользовательский алиас и приводит его к виду,
+// в котором он будет сохранен.
+func (p AliasPolicy) Normalize(alias string) (string, error) {
+	if n := utf8.RuneCountInString(alias); n < MinAliasLength || n > MaxAliasLength {
+		return "", fmt.Errorf("%w: must be %d to %d characters long", ErrInvalidAlias, MinAliasLength, MaxAliasLength)
+	}
+	if !aliasRe.MatchString(alias) {
+		return "", fmt.Errorf("%w: only latin letters, digits, '-' and '_' are allowed", ErrInvalidAlias)
+	}
+	if slices.ContainsFunc(p.Reserved, func(r string) bool { return strings.EqualFold(r, alias) }) {
+		return "", fmt.Errorf("%w: %q", ErrReservedAlias, alias)
+	}
+
+	if p.Lowercase {
+		alias = strings.ToLower(alias)
+	}
+	return alias, nil
+}
+
+// Fold возвращает код, под которым сохранен бы алиас code, если он
+// отличается от code: при Lowercase алиас "MyLink" хранится как "mylink".
+// Сгенерированные коды регистр сохраняют, поэтому код сначала ищется как
+// есть, а Fold подсказывает, где искать при промахе.
+func (p AliasPolicy) Fold(code string) (string, bool) {
+	folded := strings.ToLower(code)
+	return folded, p.Lowercase && folded != code
+}
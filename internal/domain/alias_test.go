@@ -0,0 +1,54 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliasPolicy_Reserved(t *testing.T) {
+	aliases := domain.NewAliasPolicy(false, []string{"Promo", "blog"})
+
+	for _, alias := range []string{"promo", "PROMO", "Blog", "admin", "Static"} {
+		_, err := aliases.Normalize(alias)
+		assert.ErrorIs(t, err, domain.ErrReservedAlias, alias)
+	}
+
+	alias, err := aliases.Normalize("MyLink")
+	assert.NoError(t, err)
+	assert.Equal(t, "MyLink", alias)
+
+	_, err = aliases.Normalize("my/link")
+	assert.ErrorIs(t, err, domain.ErrInvalidAlias)
+}
+
+func TestAliasPolicy_Lowercase(t *testing.T) {
+	lower := domain.NewAliasPolicy(true, nil)
+
+	alias, err := lower.Normalize("MyLink")
+	assert.NoError(t, err)
+	assert.Equal(t, "mylink", alias)
+
+	_, err = lower.Normalize("Admin")
+	assert.ErrorIs(t, err, domain.ErrReservedAlias)
+
+	folded, ok := lower.Fold("MyLink")
+	assert.True(t, ok)
+	assert.Equal(t, "mylink", folded)
+
+	_, ok = lower.Fold("mylink")
+	assert.False(t, ok, "already lowercase")
+
+	_, ok = domain.NewAliasPolicy(false, nil).Fold("MyLink")
+	assert.False(t, ok, "case is preserved")
+}
+
+func TestNewShortener_Alias(t *testing.T) {
+	link, err := domain.NewShortener(domain.NewAliasPolicy(true, nil), "MyLink", "https://example.com", domain.LinkOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "mylink", link.ShortCode)
+
+	_, err = domain.NewShortener(domain.NewAliasPolicy(false, []string{"promo"}), "Promo", "https://example.com", domain.LinkOptions{})
+	assert.ErrorIs(t, err, domain.ErrReservedAlias)
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/adexcell/shortener/pkg/utils/uuid"
+)
+
+// APIKey - ключ доступа к API. Сам ключ не хранится, только его хэш.
+type APIKey struct {
+	ID        string
+	OwnerID   string `validate:"required,max=64"`
+	Name      string `validate:"max=128"`
+	KeyHash   string `validate:"required,len=64"`
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+func NewAPIKey(ownerID, name, keyHash string) (APIKey, error) {
+	k := APIKey{
+		ID:      uuid.New(),
+		OwnerID: ownerID,
+		Name:    name,
+		KeyHash: keyHash,
+	}
+
+	if err := k.Validate(); err != nil {
+		return APIKey{}, fmt.Errorf("k.Validate: %w", err)
+	}
+
+	return k, nil
+}
+
+func (k APIKey) Validate() error {
+	err := validate.Struct(k)
+	if err != nil {
+		return fmt.Errorf("validate.Struct APIKey: %w", err)
+	}
+
+	return nil
+}
+
+type APIKeyPostgres interface {
+	Save(ctx context.Context, k APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (APIKey, error)
+	Revoke(ctx context.Context, id string) error
+}
+
+type APIKeyUsecase interface {
+	// Create выпускает новый ключ и возвращает его в открытом виде. Повторно
+	// получить открытый ключ нельзя.
+	Create(ctx context.Context, ownerID, name string) (APIKey, string, error)
+	Revoke(ctx context.Context, id string) error
+	// Authenticate находит действующий ключ по его открытому значению.
+	Authenticate(ctx context.Context, rawKey string) (APIKey, error)
+}
+
+type ownerKey struct{}
+
+// WithOwner кладет владельца запроса в контекст.
+func WithOwner(ctx context.Context, ownerID string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, ownerID)
+}
+
+// OwnerFromContext возвращает владельца запроса или "" для анонимного запроса.
+func OwnerFromContext(ctx context.Context) string {
+	ownerID, _ := ctx.Value(ownerKey{}).(string)
+	return ownerID
+}
+
+type adminKey struct{}
+
+// WithAdmin помечает запрос как запрос администратора с верным токеном.
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// IsAdmin сообщает, что запрос пришел от администратора.
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
@@ -3,5 +3,27 @@ package domain
 import "errors"
 
 var (
-	ErrAlreadyExists = errors.New("this alias is already taken")
+	ErrAlreadyExists     = errors.New("this alias is already taken")
+	ErrInvalidAlias      = errors.New("invalid alias")
+	ErrReservedAlias     = errors.New("this alias is reserved")
+	ErrNotFound          = errors.New("link not found")
+	ErrExpired           = errors.New("this link has expired")
+	ErrDisabled          = errors.New("this link is disabled")
+	ErrClicksExhausted   = errors.New("this link has reached its click limit")
+	ErrInvalidExpiration = errors.New("expiration must be in the future")
+	ErrInvalidActivation = errors.New("active_from must be before expiration")
+	ErrNotYetActive      = errors.New("this link is not active yet")
+	ErrEmptyUpdate       = errors.New("nothing to update")
+	ErrInvalidUpdate     = errors.New("update makes the link invalid")
+	ErrUnauthorized      = errors.New("invalid api key")
+	ErrForbidden         = errors.New("access denied")
+	ErrCodeExhausted     = errors.New("failed to generate a unique short code")
+	ErrPasswordRequired  = errors.New("this link is password protected")
+	ErrWrongPassword     = errors.New("wrong password")
+	ErrTooManyAttempts   = errors.New("too many attempts, try again later")
+	ErrPasswordTooLong   = errors.New("password must be at most 72 bytes")
+	ErrInvalidStatsQuery = errors.New("invalid analytics query")
+
+	ErrIdempotencyMismatch   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is still in progress")
 )
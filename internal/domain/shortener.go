@@ -4,26 +4,186 @@ package domain
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/adexcell/shortener/pkg/utils/uuid"
 	"github.com/go-playground/validator/v10"
 )
 
+// MaxShortCodeLength - предел длины кода, заданный схемой urls.short_code.
+const MaxShortCodeLength = 10
+
+// DefaultRedirectStatus - код редиректа для ссылок, где он не задан явно.
+// Временный редирект не кэшируется браузерами, поэтому каждый клик доходит до сервиса.
+const DefaultRedirectStatus = http.StatusFound
+
 type Shortener struct {
 	ID        string
 	ShortCode string
 	LongURL   string `validate:"required,url"`
+	OwnerID   string
+	// ActiveFrom - момент, до которого ссылка не редиректит (эмбарго)
+	ActiveFrom *time.Time
+	// ExpiresAt - момент, после которого ссылка перестает работать (active_until)
+	ExpiresAt *time.Time
+	// RedirectStatus - 301, 302, 307 или 308
+	RedirectStatus int `validate:"oneof=301 302 307 308"`
+	// QueryMode - проброс query-параметров перехода, см. QueryMode
+	QueryMode QueryMode `validate:"omitempty,oneof=none keep override append"`
+	// PathPassthrough - дописывать хвост пути после кода к целевому URL
+	PathPassthrough bool
+	UTM             UTM
+	// Targets - правила таргетинга по устройству, проверяются по порядку
+	Targets []TargetRule `validate:"max=20,dive"`
+	// Variants - адреса A/B-ротации; если они заданы, переход идет на один
+	// из них, а не на LongURL
+	Variants []Variant `validate:"max=10,unique=Name,dive"`
+	// StickyVariants - закреплять выбранный вариант за посетителем через cookie
+	StickyVariants bool
+	// Password - пароль в открытом виде, задается только при создании и
+	// не сохраняется: usecase заменяет его хэшем PasswordHash. Длина не
+	// больше MaxPasswordBytes байт
+	Password string `validate:"omitempty,min=4"`
+	// PasswordHash - bcrypt-хэш пароля; непустой означает, что переход
+	// требует ввода пароля
+	PasswordHash string
+	// Title - подпись ссылки для страниц предпросмотра и перехода
+	Title string `validate:"max=255"`
+	// InterstitialSeconds - сколько секунд показывать страницу перехода с
+	// адресом перед редиректом; 0 - редиректить сразу
+	InterstitialSeconds int `validate:"min=0,max=30"`
+	// MaxClicks - сколько переходов разрешено по ссылке; 0 - без ограничения
+	MaxClicks int `validate:"min=0"`
+	// Clicks - сколько переходов из MaxClicks уже израсходовано
+	Clicks    int
+	Disabled  bool
 	CreatedAt time.Time
 }
 
+// LinkOptions - необязательные настройки ссылки, задаваемые при создании.
+type LinkOptions struct {
+	ActiveFrom          *time.Time
+	ExpiresAt           *time.Time
+	RedirectStatus      int
+	QueryMode           QueryMode
+	PathPassthrough     bool
+	UTM                 UTM
+	Targets             []TargetRule
+	Variants            []Variant
+	StickyVariants      bool
+	Password            string
+	Title               string
+	InterstitialSeconds int
+	MaxClicks           int
+}
+
+// LinkUpdate описывает частичное изменение ссылки. nil-поля не меняются.
+type LinkUpdate struct {
+	LongURL         *string `validate:"omitempty,url"`
+	Disabled        *bool
+	RedirectStatus  *int       `validate:"omitempty,oneof=301 302 307 308"`
+	QueryMode       *QueryMode `validate:"omitempty,oneof=none keep override append"`
+	PathPassthrough *bool
+	// UTM заменяет шаблон меток целиком; пустой шаблон удаляет метки.
+	UTM *UTM
+	// Targets заменяет правила таргетинга целиком; пустой список удаляет их.
+	Targets *[]TargetRule `validate:"omitempty,max=20,dive"`
+	// Variants заменяет варианты ротации целиком; пустой список удаляет их.
+	Variants       *[]Variant `validate:"omitempty,max=10,unique=Name,dive"`
+	StickyVariants *bool
+	// Password задает новый пароль с теми же ограничениями, что и при
+	// создании; пустая строка снимает защиту.
+	Password *string `validate:"omitempty,eq=|min=4"`
+	// PasswordHash - хэш Password, его заполняет usecase перед сохранением.
+	PasswordHash *string
+	// Title задает подпись; пустая строка удаляет ее.
+	Title               *string `validate:"omitempty,max=255"`
+	InterstitialSeconds *int    `validate:"omitempty,min=0,max=30"`
+	// MaxClicks задает лимит переходов; 0 снимает его.
+	MaxClicks *int `validate:"omitempty,min=0"`
+	// ActiveFrom переносит начало работы ссылки или снимает эмбарго.
+	ActiveFrom *TimeUpdate
+	// ExpiresAt переносит окончание работы ссылки или делает ее бессрочной.
+	ExpiresAt *TimeUpdate
+}
+
+// TimeUpdate - новое значение необязательного момента времени в LinkUpdate.
+// Time == nil очищает поле.
+type TimeUpdate struct {
+	Time *time.Time
+}
+
+// Redirect - куда и с каким кодом перенаправить переход по ссылке.
+type Redirect struct {
+	URL        string
+	StatusCode int
+	// ExpiresAt ограничивает время, на которое можно кэшировать постоянный редирект.
+	ExpiresAt *time.Time
+	// Varies - адрес зависит от посетителя (таргетинг, ротация, пароль) или
+	// каждый переход должен дойти до сервиса (лимит кликов), и ответ нельзя
+	// кэшировать даже для постоянного редиректа.
+	Varies bool
+	// Variant - имя выбранного варианта ротации
+	Variant string
+	// StickyVariant - Variant нужно запомнить у посетителя
+	StickyVariant bool
+	// Interstitial - сколько секунд показывать страницу перехода вместо
+	// мгновенного редиректа
+	Interstitial int
+	Title        string
+	// Withheld - адрес не раскрывается: бот или HEAD-запрос к ссылке с
+	// лимитом кликов получает ответ без Location и лимит не расходует
+	Withheld bool
+}
+
+// Permanent сообщает, что редирект постоянный и может кэшироваться клиентами.
+func (r Redirect) Permanent() bool {
+	return r.StatusCode == http.StatusMovedPermanently || r.StatusCode == http.StatusPermanentRedirect
+}
+
 var validate = validator.New(validator.WithRequiredStructEnabled())
 
-func NewShortener(shortCode, longURL string) (Shortener, error) {
+// MaxPasswordBytes - предел bcrypt: длиннее пароль не захэшировать. Тег max
+// валидатора считает символы, а не байты, и кириллический пароль из 40
+// символов прошел бы его.
+const MaxPasswordBytes = 72
+
+// NewShortener создает ссылку. Непустой shortCode считается пользовательским
+// алиасом и проверяется по правилам aliases.
+func NewShortener(aliases AliasPolicy, shortCode, longURL string, opts LinkOptions) (Shortener, error) {
+	if shortCode != "" {
+		alias, err := aliases.Normalize(shortCode)
+		if err != nil {
+			return Shortener{}, err
+		}
+		shortCode = alias
+	}
+
+	if opts.RedirectStatus == 0 {
+		opts.RedirectStatus = DefaultRedirectStatus
+	}
+	if opts.QueryMode == "" {
+		opts.QueryMode = QueryDrop
+	}
+
 	s := Shortener{
-		ID:        uuid.New(),
-		ShortCode: shortCode,
-		LongURL:   longURL,
+		ID:                  uuid.New(),
+		ShortCode:           shortCode,
+		LongURL:             longURL,
+		ActiveFrom:          opts.ActiveFrom,
+		ExpiresAt:           opts.ExpiresAt,
+		RedirectStatus:      opts.RedirectStatus,
+		QueryMode:           opts.QueryMode,
+		PathPassthrough:     opts.PathPassthrough,
+		UTM:                 opts.UTM,
+		Targets:             opts.Targets,
+		Variants:            opts.Variants,
+		StickyVariants:      opts.StickyVariants,
+		Password:            opts.Password,
+		Title:               opts.Title,
+		InterstitialSeconds: opts.InterstitialSeconds,
+		MaxClicks:           opts.MaxClicks,
 	}
 
 	if err := s.Validate(); err != nil {
@@ -38,27 +198,288 @@ func (s Shortener) Validate() error {
 	if err != nil {
 		return fmt.Errorf("validate.Struct Shortener: %w", err)
 	}
+	if len(s.Password) > MaxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+
+	if s.ExpiresAt != nil && !s.ExpiresAt.After(time.Now()) {
+		return ErrInvalidExpiration
+	}
+	if s.ActiveFrom != nil && s.ExpiresAt != nil && !s.ActiveFrom.Before(*s.ExpiresAt) {
+		return ErrInvalidActivation
+	}
+
+	return nil
+}
+
+// AccessibleBy сообщает, может ли владелец ownerID видеть и менять ссылку.
+// Анонимный запрос не доступен ни к одной ссылке: ссылки без владельца,
+// созданные анонимно, может менять только администратор.
+func (s Shortener) AccessibleBy(ownerID string) bool {
+	return ownerID != "" && s.OwnerID == ownerID
+}
+
+// Deduplicable сообщает, можно ли вместо создания ссылки вернуть уже
+// существующую с тем же URL. Ссылки с алиасом или собственными настройками
+// всегда создаются заново.
+func (s Shortener) Deduplicable() bool {
+	return s.ShortCode == "" &&
+		s.ExpiresAt == nil &&
+		s.ActiveFrom == nil &&
+		s.Redirect().StatusCode == DefaultRedirectStatus &&
+		(s.QueryMode == "" || s.QueryMode == QueryDrop) &&
+		!s.PathPassthrough &&
+		s.UTM.IsZero() &&
+		len(s.Targets) == 0 &&
+		len(s.Variants) == 0 &&
+		!s.Protected() &&
+		s.Title == "" &&
+		s.InterstitialSeconds == 0 &&
+		s.MaxClicks == 0
+}
+
+// Protected сообщает, что переход по ссылке требует пароля.
+func (s Shortener) Protected() bool {
+	return s.Password != "" || s.PasswordHash != ""
+}
+
+// Personalized сообщает, что адрес перехода зависит от посетителя.
+func (s Shortener) Personalized() bool {
+	return len(s.Targets) > 0 || len(s.Variants) > 0 || s.Protected()
+}
+
+// Redirect возвращает параметры редиректа ссылки. Редирект ссылки с лимитом
+// не кэшируется: закэшированный браузером или прокси переход не дошел бы до
+// ConsumeClick.
+func (s Shortener) Redirect() Redirect {
+	status := s.RedirectStatus
+	if status == 0 {
+		status = DefaultRedirectStatus
+	}
+
+	return Redirect{
+		URL:          s.LongURL,
+		StatusCode:   status,
+		ExpiresAt:    s.ExpiresAt,
+		Varies:       s.Personalized() || s.Limited(),
+		Interstitial: s.InterstitialSeconds,
+		Title:        s.Title,
+	}
+}
+
+// Available проверяет, что по ссылке можно перейти в момент now.
+func (s Shortener) Available(now time.Time) error {
+	if s.ActiveFrom != nil && now.Before(*s.ActiveFrom) {
+		return ErrNotYetActive
+	}
+	if s.IsExpired(now) {
+		return ErrExpired
+	}
+	if s.Disabled {
+		return ErrDisabled
+	}
+	if s.Exhausted() {
+		return ErrClicksExhausted
+	}
+	return nil
+}
+
+// Limited сообщает, что у ссылки есть лимит переходов, и каждый переход
+// нужно засчитывать через ShortenerPostgres.ConsumeClick.
+func (s Shortener) Limited() bool {
+	return s.MaxClicks > 0
+}
+
+// Exhausted сообщает, что лимит переходов ссылки израсходован.
+func (s Shortener) Exhausted() bool {
+	return s.Limited() && s.Clicks >= s.MaxClicks
+}
+
+// IsExpired сообщает, истек ли срок жизни ссылки к моменту now.
+func (s Shortener) IsExpired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+func (u LinkUpdate) Validate() error {
+	if u.LongURL == nil && u.Disabled == nil && u.RedirectStatus == nil &&
+		u.QueryMode == nil && u.PathPassthrough == nil && u.UTM == nil && u.Targets == nil &&
+		u.Variants == nil && u.StickyVariants == nil && u.Password == nil &&
+		u.Title == nil && u.InterstitialSeconds == nil && u.MaxClicks == nil &&
+		u.ActiveFrom == nil && u.ExpiresAt == nil {
+		return ErrEmptyUpdate
+	}
+
+	err := validate.Struct(u)
+	if err != nil {
+		return fmt.Errorf("validate.Struct LinkUpdate: %w", err)
+	}
+	if u.Password != nil && len(*u.Password) > MaxPasswordBytes {
+		return ErrPasswordTooLong
+	}
 
 	return nil
 }
 
+// Apply возвращает ссылку с примененным изменением.
+func (s Shortener) Apply(u LinkUpdate) Shortener {
+	if u.LongURL != nil {
+		s.LongURL = *u.LongURL
+	}
+	if u.Disabled != nil {
+		s.Disabled = *u.Disabled
+	}
+	if u.RedirectStatus != nil {
+		s.RedirectStatus = *u.RedirectStatus
+	}
+	if u.QueryMode != nil {
+		s.QueryMode = *u.QueryMode
+	}
+	if u.PathPassthrough != nil {
+		s.PathPassthrough = *u.PathPassthrough
+	}
+	if u.UTM != nil {
+		s.UTM = *u.UTM
+	}
+	if u.Targets != nil {
+		s.Targets = *u.Targets
+	}
+	if u.Variants != nil {
+		s.Variants = *u.Variants
+	}
+	if u.StickyVariants != nil {
+		s.StickyVariants = *u.StickyVariants
+	}
+	if u.PasswordHash != nil {
+		s.PasswordHash = *u.PasswordHash
+	}
+	if u.Title != nil {
+		s.Title = *u.Title
+	}
+	if u.InterstitialSeconds != nil {
+		s.InterstitialSeconds = *u.InterstitialSeconds
+	}
+	if u.MaxClicks != nil {
+		s.MaxClicks = *u.MaxClicks
+	}
+	if u.ActiveFrom != nil {
+		s.ActiveFrom = u.ActiveFrom.Time
+	}
+	if u.ExpiresAt != nil {
+		s.ExpiresAt = u.ExpiresAt.Time
+	}
+
+	return s
+}
+
+// ValidateFor проверяет ссылку, которая получится из link после изменения.
+// Срок жизни в прошлом запрещен, только если изменение его задает: уже
+// истекшую ссылку можно выключить или переименовать.
+func (u LinkUpdate) ValidateFor(link Shortener, now time.Time) error {
+	merged := link.Apply(u)
+	merged.RedirectStatus = merged.Redirect().StatusCode
+
+	err := validate.Struct(merged)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidUpdate, err)
+	}
+
+	if u.ExpiresAt != nil && merged.ExpiresAt != nil && !merged.ExpiresAt.After(now) {
+		return ErrInvalidExpiration
+	}
+	if merged.ActiveFrom != nil && merged.ExpiresAt != nil && !merged.ActiveFrom.Before(*merged.ExpiresAt) {
+		return ErrInvalidActivation
+	}
+
+	return nil
+}
+
+// Idempotency - ключ идемпотентности из заголовка Idempotency-Key и отпечаток
+// тела запроса, по которому повтор отличается от другого запроса с тем же ключом.
+type Idempotency struct {
+	Key         string
+	Fingerprint string
+}
+
+// BatchResult - итог создания одной ссылки из пакета. Err == nil означает,
+// что ссылка создана под кодом ShortCode.
+type BatchResult struct {
+	ShortCode string
+	Err       error
+}
+
+// CacheEntry - запись для пакетной записи в Redis.
+type CacheEntry struct {
+	Key   string
+	Value any
+	TTL   time.Duration
+}
+
+// CodeGenerator генерирует короткие коды для ссылок без алиаса.
+type CodeGenerator interface {
+	Generate(ctx context.Context, length int) (string, error)
+}
+
 type ShortenerPostgres interface {
-	Save(ctx context.Context, shortCode, longURL string) error
-	GetLongURL(ctx context.Context, shortCode string) (string, error)
-	SaveClick(ctx context.Context, shortCode, ip, userAgent string) error
-	GetDetailedStats(ctx context.Context, shortCode string) (Stats, error)
+	Save(ctx context.Context, s Shortener) error
+	// SaveBatch вставляет ссылки одной транзакцией, пропуская занятые коды,
+	// и возвращает коды, которые удалось вставить.
+	SaveBatch(ctx context.Context, links []Shortener) (map[string]bool, error)
+	Get(ctx context.Context, shortCode string) (Shortener, error)
+	// Owner возвращает владельца кода, в том числе удаленной ссылки.
+	Owner(ctx context.Context, shortCode string) (string, error)
+	// FindDuplicate ищет действующую ссылку владельца на longURL без
+	// собственных настроек, которую можно вернуть вместо новой.
+	FindDuplicate(ctx context.Context, ownerID, longURL string) (Shortener, error)
+	Update(ctx context.Context, shortCode string, upd LinkUpdate) (Shortener, error)
+	Delete(ctx context.Context, shortCode string) error
+	// ConsumeClick атомарно засчитывает переход по ссылке с лимитом и
+	// возвращает ErrClicksExhausted, если лимит уже израсходован.
+	ConsumeClick(ctx context.Context, shortCode string) error
+	// NextID выдает следующее значение счетчика для генерации кодов.
+	NextID(ctx context.Context) (uint64, error)
+	SaveClick(ctx context.Context, click Stats) error
+	GetDetailedStats(ctx context.Context, shortCode string, q StatsQuery) (Stats, error)
 	Close() error
 }
 
 type ShortenerRedis interface {
 	SetWithExpiration(ctx context.Context, key string, value any, expiration time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
+	// SetNX записывает значение, только если ключа еще нет.
+	SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error)
+	// SetMany записывает несколько ключей одним пайплайном.
+	SetMany(ctx context.Context, entries []CacheEntry) error
+	// Incr увеличивает счетчик; ttl задается при создании ключа и не
+	// продлевается следующими вызовами.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	Del(ctx context.Context, key string) error
+	// PFAdd добавляет элемент в HyperLogLog key и продлевает ключ на ttl.
+	PFAdd(ctx context.Context, key, member string, ttl time.Duration) error
+	// PFCount возвращает оценку числа уникальных элементов HyperLogLog.
+	PFCount(ctx context.Context, key string) (int64, error)
 	Close() error
 }
 
 type ShortenerUsecase interface {
-	Shorten(ctx context.Context, longURL, alias string) (string, error)
-	GetOriginal(ctx context.Context, shortCode, ip, userAgent string) (string, error)
-	GetStats(ctx context.Context, shortCode string) (Stats, error)
+	// Shorten создает ссылку. Повтор запроса с тем же непустым
+	// ключом идемпотентности возвращает результат первого запроса.
+	Shorten(ctx context.Context, s Shortener, idem Idempotency) (string, error)
+	// ShortenBatch создает ссылки пакетом. Результаты идут в порядке links;
+	// ошибка возвращается, только если не удалось обработать пакет целиком.
+	ShortenBatch(ctx context.Context, links []Shortener) ([]BatchResult, error)
+	// GetOriginal возвращает редирект для перехода и засчитывает клик.
+	// Для ссылки с паролем без верного Visit.Password возвращает
+	// ErrPasswordRequired или ErrWrongPassword, и клик не засчитывается.
+	GetOriginal(ctx context.Context, v Visit) (Redirect, error)
+	// Preview возвращает действующую ссылку для страницы предпросмотра, не
+	// засчитывая клик.
+	Preview(ctx context.Context, shortCode string) (Shortener, error)
+	// Fallback возвращает адрес, куда перенаправить переход по
+	// несуществующему или недоступному коду, если он настроен.
+	Fallback(ctx context.Context, shortCode string) (string, bool)
+	GetStats(ctx context.Context, shortCode string, q StatsQuery) (Stats, error)
+	GetLink(ctx context.Context, shortCode string) (Shortener, error)
+	UpdateLink(ctx context.Context, shortCode string, upd LinkUpdate) (Shortener, error)
+	DeleteLink(ctx context.Context, shortCode string) error
 	Close() error
 }
@@ -8,14 +8,66 @@ import (
 )
 
 type Stats struct {
-	ID          string
-	ShortCode   string
-	IP          string `validate:"required,ip"`
-	UserAgent   string
+	ID        string
+	ShortCode string
+	IP        string `validate:"required,ip"`
+	UserAgent string
+	// Campaign - utm_campaign адреса, на который ушел клик
+	Campaign string
+	// Country - страна клика по GeoIP, пустая, если база не подключена
+	Country string
+	// Variant - вариант ротации, на который ушел клик
+	Variant string
+	// Source - канал перехода, например SourceQR; пусто для обычного клика
+	Source string
+	// Browser, BrowserVersion, OS и Device - результат разбора UserAgent в
+	// момент клика; пустое значение означает, что определить не удалось
+	Browser        string
+	BrowserVersion string
+	OS             string
+	Device         string
+	// Bot - клик краулера, сервиса превью ссылок или HTTP-утилиты
+	Bot bool
+	// BotName - имя бота, например "slack" или "google"; пусто для человека
+	BotName string
+	// Referrer - хост страницы, с которой пришел клик; пусто для прямого перехода
+	Referrer string
+	// VisitorID - анонимный отпечаток посетителя, уникальный в пределах
+	// суток; пусто, если подсчет посетителей выключен
+	VisitorID string
+	// TotalClicks - клики за период; клики ботов учитываются в нем и во всех
+	// разбивках, только если запрошено StatsQuery.IncludeBots
 	TotalClicks int
-	ByDate      map[string]int
-	ByBrowser   map[string]int
-	ClickedAt   time.Time
+	// BotClicks - клики ботов за период независимо от IncludeBots
+	BotClicks int
+	// DailyUniqueVisitors - сумма уникальных посетителей по суткам периода,
+	// точно по Postgres. Соль отпечатка меняется раз в сутки, поэтому
+	// посетитель, вернувшийся в другие сутки, считается заново, и число
+	// уникальных посетителей за весь период по отпечаткам не получить
+	DailyUniqueVisitors int
+	// Live - оценка уникальных посетителей в реальном времени по Redis
+	Live *LiveVisitors
+	// Series - клики по интервалам запрошенного периода, по порядку
+	Series     []StatsPoint
+	ByBrowser  map[string]int
+	ByCampaign map[string]int
+	ByVariant  map[string]int
+	BySource   map[string]int
+	ByOS       map[string]int
+	ByDevice   map[string]int
+	ByReferrer map[string]int
+	// ByBot - клики ботов за период по имени бота
+	ByBot     map[string]int
+	ClickedAt time.Time
+}
+
+// LiveVisitors - оценка уникальных посетителей ссылки по HyperLogLog.
+type LiveVisitors struct {
+	// Today - за текущие сутки (UTC)
+	Today int
+	// DailyTotal - сумма посетителей по суткам за все время: посетитель
+	// считается заново в каждые сутки
+	DailyTotal int
 }
 
 func NewStats(shortCode, ip, userAgent string) (Stats, error) {
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Шаг временного ряда статистики.
+const (
+	GranularityHour  = "hour"
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// MaxStatsBuckets ограничивает длину временного ряда, например 41 день по
+// часам.
+const MaxStatsBuckets = 1000
+
+// defaultStatsBuckets - сколько последних интервалов показывать, если from
+// не задан: сутки по часам, неделя по дням, квартал по неделям, год по месяцам.
+var defaultStatsBuckets = map[string]int{
+	GranularityHour:  24,
+	GranularityDay:   7,
+	GranularityWeek:  13,
+	GranularityMonth: 12,
+}
+
+// StatsQuery - период и шаг временного ряда статистики. Интервалы
+// выравниваются по календарю часового пояса Location: сутки начинаются в
+// полночь, недели - в понедельник.
+type StatsQuery struct {
+	// From и To - полуинтервал [From, To) кликов
+	From        time.Time
+	To          time.Time
+	Granularity string
+	Location    *time.Location
+	// IncludeBots - учитывать клики ботов в общих счетчиках и разбивках
+	IncludeBots bool
+}
+
+// StatsPoint - число кликов и уникальных посетителей за интервал,
+// начинающийся в Time. Для интервалов длиннее суток Unique - сумма
+// уникальных посетителей по суткам.
+type StatsPoint struct {
+	Time   time.Time
+	Clicks int
+	Unique int
+}
+
+// NewStatsQuery разбирает параметры запроса статистики. from и to - RFC 3339
+// или дата (2006-01-02) в часовом поясе tz; пустые значения означают
+// последние интервалы по defaultStatsBuckets до текущего момента.
+func NewStatsQuery(from, to, granularity, tz string, now time.Time) (StatsQuery, error) {
+	q := StatsQuery{Granularity: granularity, Location: time.UTC}
+	if q.Granularity == "" {
+		q.Granularity = GranularityDay
+	}
+	if _, ok := defaultStatsBuckets[q.Granularity]; !ok {
+		return StatsQuery{}, fmt.Errorf("%w: granularity must be hour, day, week or month", ErrInvalidStatsQuery)
+	}
+
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		// "Local" - пояс сервера, Postgres о нем не знает.
+		if err != nil || tz == "Local" {
+			return StatsQuery{}, fmt.Errorf("%w: unknown tz %q", ErrInvalidStatsQuery, tz)
+		}
+		q.Location = loc
+	}
+
+	var err error
+	q.To = now.In(q.Location)
+	if to != "" {
+		if q.To, err = parseStatsTime(to, q.Location); err != nil {
+			return StatsQuery{}, err
+		}
+	}
+	if from != "" {
+		if q.From, err = parseStatsTime(from, q.Location); err != nil {
+			return StatsQuery{}, err
+		}
+	} else {
+		q.From = q.add(q.truncate(q.To), 1-defaultStatsBuckets[q.Granularity])
+	}
+
+	if !q.From.Before(q.To) {
+		return StatsQuery{}, fmt.Errorf("%w: from must be before to", ErrInvalidStatsQuery)
+	}
+	if q.buckets() > MaxStatsBuckets {
+		return StatsQuery{}, fmt.Errorf("%w: range has more than %d %s buckets", ErrInvalidStatsQuery, MaxStatsBuckets, q.Granularity)
+	}
+
+	return q, nil
+}
+
+func parseStatsTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: %q is not an RFC 3339 time or a date", ErrInvalidStatsQuery, s)
+}
+
+// Series возвращает начала всех интервалов периода по порядку.
+func (q StatsQuery) Series() []time.Time {
+	var series []time.Time
+	for t := q.truncate(q.From); t.Before(q.To); t = q.add(t, 1) {
+		series = append(series, t)
+	}
+	return series
+}
+
+// buckets считает интервалы периода, не строя ряд целиком.
+func (q StatsQuery) buckets() int {
+	n := 0
+	for t := q.truncate(q.From); t.Before(q.To) && n <= MaxStatsBuckets; t = q.add(t, 1) {
+		n++
+	}
+	return n
+}
+
+// truncate возвращает начало интервала, в который попадает t.
+func (q StatsQuery) truncate(t time.Time) time.Time {
+	t = t.In(q.Location)
+	y, m, d := t.Date()
+	switch q.Granularity {
+	case GranularityHour:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, q.Location)
+	case GranularityWeek:
+		// Неделя начинается в понедельник, как в date_trunc Postgres.
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, q.Location)
+	case GranularityMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, q.Location)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, q.Location)
+	}
+}
+
+// add сдвигает начало интервала на n интервалов по календарю, чтобы сутки
+// с переходом на летнее время не сбивали ряд.
+func (q StatsQuery) add(t time.Time, n int) time.Time {
+	switch q.Granularity {
+	case GranularityHour:
+		return t.Add(time.Duration(n) * time.Hour)
+	case GranularityWeek:
+		return t.AddDate(0, 0, 7*n)
+	case GranularityMonth:
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
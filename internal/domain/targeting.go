@@ -0,0 +1,47 @@
+package domain
+
+// TargetRule - правило таргетинга: переход с подходящего устройства или из
+// подходящей страны уходит на URL правила вместо LongURL. Пустое условие
+// подходит под любое значение, но хотя бы одно условие должно быть задано.
+type TargetRule struct {
+	// Platform - ios, android, windows, macos, linux или chromeos
+	Platform string `validate:"required_without_all=Device Country,omitempty,oneof=ios android windows macos linux chromeos"`
+	// Device - mobile, tablet или desktop
+	Device string `validate:"omitempty,oneof=mobile tablet desktop"`
+	// Country - ISO 3166-1 alpha-2 код страны в верхнем регистре
+	Country string `validate:"omitempty,iso3166_1_alpha2"`
+	URL     string `validate:"required,url"`
+}
+
+// Client - то, что известно о переходе для выбора правила таргетинга.
+type Client struct {
+	Platform string
+	Device   string
+	Country  string
+}
+
+// Matches сообщает, подходит ли правило под переход.
+func (r TargetRule) Matches(c Client) bool {
+	return (r.Platform == "" || r.Platform == c.Platform) &&
+		(r.Device == "" || r.Device == c.Device) &&
+		(r.Country == "" || r.Country == c.Country)
+}
+
+// Target возвращает URL первого подходящего правила таргетинга. Если ни одно
+// правило не подошло, ok == false.
+func (s Shortener) Target(c Client) (url string, ok bool) {
+	for _, rule := range s.Targets {
+		if rule.Matches(c) {
+			return rule.URL, true
+		}
+	}
+
+	return "", false
+}
+
+// GeoLocator определяет страну по IP.
+type GeoLocator interface {
+	// Country возвращает ISO 3166-1 alpha-2 код страны или пустую строку,
+	// если страну определить не удалось.
+	Country(ip string) string
+}
@@ -0,0 +1,44 @@
+package domain
+
+import "net/url"
+
+// UTM - шаблон UTM-меток ссылки. Метки хранятся отдельно от LongURL и
+// добавляются к целевому URL при переходе, поэтому их можно менять, не
+// трогая саму ссылку. Пустые поля не добавляются.
+type UTM struct {
+	Source   string `validate:"max=255"`
+	Medium   string `validate:"max=255"`
+	Campaign string `validate:"max=255"`
+	Term     string `validate:"max=255"`
+	Content  string `validate:"max=255"`
+}
+
+// IsZero сообщает, что в шаблоне нет ни одной метки.
+func (u UTM) IsZero() bool {
+	return u == UTM{}
+}
+
+// apply записывает метки шаблона в query, заменяя одноименные параметры.
+func (u UTM) apply(query url.Values) {
+	for key, value := range map[string]string{
+		"utm_source":   u.Source,
+		"utm_medium":   u.Medium,
+		"utm_campaign": u.Campaign,
+		"utm_term":     u.Term,
+		"utm_content":  u.Content,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+}
+
+// CampaignOf возвращает utm_campaign адреса перехода, по которому клик
+// попадает в разбивку по кампаниям.
+func CampaignOf(destination string) string {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("utm_campaign")
+}
@@ -0,0 +1,38 @@
+package domain
+
+// Variant - один из адресов ротации ссылки. Доля переходов на вариант
+// пропорциональна его весу.
+type Variant struct {
+	// Name - имя варианта в аналитике и sticky-cookie, уникально в пределах ссылки
+	Name   string `validate:"required,max=64"`
+	URL    string `validate:"required,url"`
+	Weight int    `validate:"min=1,max=1000"`
+}
+
+// ChooseVariant выбирает вариант перехода. Вариант sticky, запомненный у
+// посетителя, выбирается снова, если он еще есть у ссылки; иначе выбор
+// случайный с учетом весов, а pick(n) должен вернуть число из [0, n).
+// У ссылки без вариантов ok == false.
+func (s Shortener) ChooseVariant(sticky string, pick func(n int) int) (v Variant, ok bool) {
+	if len(s.Variants) == 0 {
+		return Variant{}, false
+	}
+
+	total := 0
+	for _, v := range s.Variants {
+		if sticky != "" && v.Name == sticky {
+			return v, true
+		}
+		total += v.Weight
+	}
+
+	roll := pick(total)
+	for _, v := range s.Variants {
+		if roll < v.Weight {
+			return v, true
+		}
+		roll -= v.Weight
+	}
+
+	return s.Variants[len(s.Variants)-1], true
+}
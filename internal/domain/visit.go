@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// QueryMode - политика проброса query-параметров перехода в целевой URL.
+type QueryMode string
+
+const (
+	// QueryDrop отбрасывает параметры перехода: редирект идет на сохраненный URL как есть.
+	QueryDrop QueryMode = "none"
+	// QueryKeep добавляет параметры перехода, но при совпадении ключей
+	// оставляет сохраненные значения.
+	QueryKeep QueryMode = "keep"
+	// QueryOverride заменяет сохраненные значения параметрами перехода.
+	QueryOverride QueryMode = "override"
+	// QueryAppend оставляет сохраненные значения и дописывает к ним значения перехода.
+	QueryAppend QueryMode = "append"
+)
+
+// SourceQR - метка перехода по QR-коду ссылки.
+const SourceQR = "qr"
+
+// BotHead - имя бота для HEAD-запроса с User-Agent, не похожим на бота:
+// браузеры за ссылками HEAD-запросами не ходят.
+const BotHead = "head"
+
+// Visit - переход по короткой ссылке.
+type Visit struct {
+	ShortCode string
+	IP        string
+	UserAgent string
+	// Path - хвост пути после кода, например "/extra/path" для /s/abc/extra/path
+	Path  string
+	Query url.Values
+	// Variant - вариант ротации, закрепленный за посетителем sticky-cookie
+	Variant string
+	// Password - пароль, введенный посетителем в форме защищенной ссылки
+	Password string
+	// Source - канал, из которого пришел переход, например SourceQR
+	Source string
+	// Referrer - заголовок Referer перехода
+	Referrer string
+	// Method - HTTP-метод перехода; HEAD отправляют только боты и утилиты
+	Method string
+}
+
+// Destination возвращает адрес перехода: сохраненный URL с UTM-метками
+// ссылки и пробросом хвоста пути и query-параметров по ее настройкам. Хвост
+// пути у ссылки без PathPassthrough означает несуществующий адрес и дает
+// ErrNotFound.
+func (s Shortener) Destination(v Visit) (string, error) {
+	extra := strings.TrimRight(v.Path, "/")
+	mode := s.QueryMode
+	if mode == "" {
+		mode = QueryDrop
+	}
+
+	if extra != "" && !s.PathPassthrough {
+		return "", ErrNotFound
+	}
+
+	passQuery := mode != QueryDrop && len(v.Query) > 0
+	if extra == "" && !passQuery && s.UTM.IsZero() {
+		return s.LongURL, nil
+	}
+
+	dst, err := url.Parse(s.LongURL)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse: %w", err)
+	}
+
+	if extra != "" {
+		// path.Clean не дает хвосту подняться выше пути сохраненного URL.
+		dst.Path = strings.TrimRight(dst.Path, "/") + path.Clean("/"+extra)
+		dst.RawPath = ""
+	}
+
+	if passQuery || !s.UTM.IsZero() {
+		// Метки шаблона считаются частью сохраненного URL: при конфликте
+		// с параметрами перехода действует та же политика QueryMode.
+		query := dst.Query()
+		s.UTM.apply(query)
+		if passQuery {
+			query = mergeQuery(query, v.Query, mode)
+		}
+		dst.RawQuery = query.Encode()
+	}
+
+	return dst.String(), nil
+}
+
+// ReferrerHost возвращает хост страницы, с которой пришел переход, для
+// разбивки по источникам трафика: "https://www.Google.com/search?q=x" ->
+// "google.com". Пустой или неразборчивый Referer означает прямой переход и
+// дает "".
+func ReferrerHost(referrer string) string {
+	u, err := url.Parse(strings.TrimSpace(referrer))
+	if err != nil {
+		return ""
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+	return strings.TrimPrefix(host, "www.")
+}
+
+func mergeQuery(stored, incoming url.Values, mode QueryMode) url.Values {
+	for key, values := range incoming {
+		_, exists := stored[key]
+		switch {
+		case !exists, mode == QueryOverride:
+			stored[key] = values
+		case mode == QueryAppend:
+			stored[key] = append(stored[key], values...)
+		}
+	}
+
+	return stored
+}
@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/log"
+	"github.com/adexcell/shortener/pkg/utils/uuid"
+)
+
+// apiKeyPrefix помогает узнать ключ сервиса в логах и конфигах.
+const apiKeyPrefix = "sk_"
+
+type APIKeyUsecase struct {
+	log      log.Log
+	postgres domain.APIKeyPostgres
+}
+
+func NewAPIKeyUsecase(p domain.APIKeyPostgres, l log.Log) domain.APIKeyUsecase {
+	return &APIKeyUsecase{
+		log:      l,
+		postgres: p,
+	}
+}
+
+// Create генерирует случайный ключ и сохраняет в БД только его хэш.
+func (u *APIKeyUsecase) Create(ctx context.Context, ownerID, name string) (domain.APIKey, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return domain.APIKey{}, "", fmt.Errorf("rand.Read: %w", err)
+	}
+	rawKey := apiKeyPrefix + hex.EncodeToString(b)
+
+	k, err := domain.NewAPIKey(ownerID, name, hashAPIKey(rawKey))
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	if err := u.postgres.Save(ctx, k); err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	return k, rawKey, nil
+}
+
+func (u *APIKeyUsecase) Revoke(ctx context.Context, id string) error {
+	if err := uuid.Parse(id); err != nil {
+		return domain.ErrNotFound
+	}
+
+	return u.postgres.Revoke(ctx, id)
+}
+
+func (u *APIKeyUsecase) Authenticate(ctx context.Context, rawKey string) (domain.APIKey, error) {
+	k, err := u.postgres.GetByHash(ctx, hashAPIKey(rawKey))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.APIKey{}, domain.ErrUnauthorized
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+
+	if k.RevokedAt != nil {
+		return domain.APIKey{}, domain.ErrUnauthorized
+	}
+
+	return k, nil
+}
+
+// hashAPIKey хэширует ключ для хранения и поиска. Ключи содержат 256 бит
+// случайности, поэтому медленный хэш с солью здесь не нужен.
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
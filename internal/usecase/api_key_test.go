@@ -0,0 +1,83 @@
+package usecase_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/internal/usecase"
+	"github.com/adexcell/shortener/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// --- Mocks ---
+
+type MockAPIKeyPostgres struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyPostgres) Save(ctx context.Context, k domain.APIKey) error {
+	args := m.Called(ctx, k)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyPostgres) GetByHash(ctx context.Context, keyHash string) (domain.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	return args.Get(0).(domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyPostgres) Revoke(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// --- Tests ---
+
+func TestAPIKeyUsecase(t *testing.T) {
+	ctx := context.Background()
+	mockPg := new(MockAPIKeyPostgres)
+	uc := usecase.NewAPIKeyUsecase(mockPg, log.New())
+
+	t.Run("create stores only hash", func(t *testing.T) {
+		var saved domain.APIKey
+		mockPg.On("Save", ctx, mock.AnythingOfType("domain.APIKey")).Run(func(args mock.Arguments) {
+			saved = args.Get(1).(domain.APIKey)
+		}).Return(nil).Once()
+
+		k, rawKey, err := uc.Create(ctx, "marketing", "campaigns")
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(rawKey, "sk_"))
+		assert.Equal(t, "marketing", k.OwnerID)
+		assert.NotContains(t, saved.KeyHash, rawKey)
+		assert.Len(t, saved.KeyHash, 64)
+		mockPg.AssertExpectations(t)
+
+		mockPg.On("GetByHash", ctx, saved.KeyHash).Return(saved, nil).Once()
+
+		got, err := uc.Authenticate(ctx, rawKey)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "marketing", got.OwnerID)
+	})
+
+	t.Run("revoked key is rejected", func(t *testing.T) {
+		revokedAt := time.Now()
+		mockPg.On("GetByHash", ctx, mock.AnythingOfType("string")).Return(domain.APIKey{OwnerID: "marketing", RevokedAt: &revokedAt}, nil).Once()
+
+		_, err := uc.Authenticate(ctx, "sk_revoked")
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("unknown key is rejected", func(t *testing.T) {
+		mockPg.On("GetByHash", ctx, mock.AnythingOfType("string")).Return(domain.APIKey{}, domain.ErrNotFound).Once()
+
+		_, err := uc.Authenticate(ctx, "sk_unknown")
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adexcell/shortener/internal/domain"
+)
+
+// maxUniqueAttempts ограничивает перегенерацию кода, совпавшего с другим
+// кодом того же пакета.
+const maxUniqueAttempts = 10
+
+// ShortenBatch создает ссылки пакетом: каждая попытка - один INSERT в
+// транзакции. Занятые алиасы сразу помечаются конфликтом, а ссылки со
+// сгенерированным кодом, попавшим в коллизию, получают новый, более длинный
+// код в следующей попытке. Созданные ссылки прогреваются в Redis одним пайплайном.
+func (u *ShortenerUsecase) ShortenBatch(ctx context.Context, links []domain.Shortener) ([]domain.BatchResult, error) {
+	owner := domain.OwnerFromContext(ctx)
+	results := make([]domain.BatchResult, len(links))
+
+	// taken - коды, уже занятые внутри пакета: по ответу ON CONFLICT нельзя
+	// понять, какая из двух строк с одинаковым кодом была вставлена.
+	taken := make(map[string]bool, len(links))
+	generated := make(map[int]bool)
+	var aliased []int
+	hashErrs := hashPasswords(links)
+	for i := range links {
+		links[i].OwnerID = owner
+		if err := hashErrs[i]; err != nil {
+			results[i].Err = err
+			continue
+		}
+		code := links[i].ShortCode
+		switch {
+		case code == "":
+			generated[i] = true
+		case taken[code]:
+			results[i].Err = domain.ErrAlreadyExists
+		default:
+			taken[code] = true
+			aliased = append(aliased, i)
+		}
+	}
+
+	pending := aliased
+	retry := make([]int, 0, len(generated))
+	for i := range links {
+		if generated[i] {
+			retry = append(retry, i)
+		}
+	}
+
+	length := u.cfg.CodeLength
+	for range u.cfg.MaxAttempts {
+		if len(pending) == 0 && len(retry) == 0 {
+			break
+		}
+
+		for _, i := range retry {
+			code, err := u.generateUnique(ctx, length, taken)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			links[i].ShortCode = code
+			pending = append(pending, i)
+		}
+		retry = retry[:0]
+
+		batch := make([]domain.Shortener, 0, len(pending))
+		for _, i := range pending {
+			batch = append(batch, links[i])
+		}
+
+		inserted, err := u.postgres.SaveBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range pending {
+			code := links[i].ShortCode
+			switch {
+			case inserted[code]:
+				results[i].ShortCode = code
+			case generated[i]:
+				u.log.Warn().Str("code", code).Msg("generated short code collision, retrying")
+				retry = append(retry, i)
+			default:
+				results[i].Err = domain.ErrAlreadyExists
+			}
+		}
+		pending = pending[:0]
+
+		if length < domain.MaxShortCodeLength {
+			length++
+		}
+	}
+
+	for _, i := range retry {
+		results[i].Err = domain.ErrCodeExhausted
+	}
+
+	u.cacheBatch(ctx, links, results)
+
+	return results, nil
+}
+
+// generateUnique генерирует код, не совпадающий с уже занятыми в пакете.
+func (u *ShortenerUsecase) generateUnique(ctx context.Context, length int, taken map[string]bool) (string, error) {
+	for range maxUniqueAttempts {
+		code, err := u.generator.Generate(ctx, length)
+		if err != nil {
+			return "", fmt.Errorf("u.generator.Generate: %w", err)
+		}
+		if !taken[code] {
+			taken[code] = true
+			return code, nil
+		}
+	}
+
+	return "", domain.ErrCodeExhausted
+}
+
+// cacheBatch прогревает кэш созданными ссылками. Ошибка Redis не влияет на
+// результат: ссылки уже сохранены и будут закэшированы при первом переходе.
+func (u *ShortenerUsecase) cacheBatch(ctx context.Context, links []domain.Shortener, results []domain.BatchResult) {
+	entries := make([]domain.CacheEntry, 0, len(links))
+	for i, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		if entry, ok := u.cacheEntry(links[i]); ok {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	if err := u.redis.SetMany(ctx, entries); err != nil {
+		u.log.Error().Err(err).Int("count", len(entries)).Msg("failed to cache batch in redis")
+	}
+}
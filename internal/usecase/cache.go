@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/adexcell/shortener/internal/domain"
+)
+
+// cachedLink - то, что хранится в Redis по коду ссылки: все, что нужно
+// для редиректа без обращения к Postgres.
+type cachedLink struct {
+	URL             string              `json:"url"`
+	Status          int                 `json:"status"`
+	ExpiresAt       *time.Time          `json:"expires_at,omitempty"`
+	QueryMode       domain.QueryMode    `json:"query_mode,omitempty"`
+	PathPassthrough bool                `json:"path_passthrough,omitempty"`
+	UTM             *domain.UTM         `json:"utm,omitempty"`
+	Targets         []domain.TargetRule `json:"targets,omitempty"`
+	Variants        []domain.Variant    `json:"variants,omitempty"`
+	StickyVariants  bool                `json:"sticky_variants,omitempty"`
+	PasswordHash    string              `json:"password_hash,omitempty"`
+	Title           string              `json:"title,omitempty"`
+	Interstitial    int                 `json:"interstitial,omitempty"`
+	MaxClicks       int                 `json:"max_clicks,omitempty"`
+}
+
+// cached ищет ссылку в Redis. Промах, ошибка Redis и нечитаемая запись
+// одинаково означают, что ссылку нужно загрузить из Postgres.
+func (u *ShortenerUsecase) cached(ctx context.Context, shortCode string) (domain.Shortener, bool) {
+	raw, err := u.redis.Get(ctx, shortCode)
+	if err != nil {
+		return domain.Shortener{}, false
+	}
+
+	var link cachedLink
+	if err := json.Unmarshal([]byte(raw), &link); err != nil {
+		u.log.Warn().Err(err).Str("code", shortCode).Msg("invalid cache entry, falling back to db")
+		return domain.Shortener{}, false
+	}
+
+	var utm domain.UTM
+	if link.UTM != nil {
+		utm = *link.UTM
+	}
+
+	return domain.Shortener{
+		ShortCode:           shortCode,
+		LongURL:             link.URL,
+		ExpiresAt:           link.ExpiresAt,
+		RedirectStatus:      link.Status,
+		QueryMode:           link.QueryMode,
+		PathPassthrough:     link.PathPassthrough,
+		UTM:                 utm,
+		Targets:             link.Targets,
+		Variants:            link.Variants,
+		StickyVariants:      link.StickyVariants,
+		PasswordHash:        link.PasswordHash,
+		Title:               link.Title,
+		InterstitialSeconds: link.Interstitial,
+		MaxClicks:           link.MaxClicks,
+	}, true
+}
+
+// cache кладет ссылку в Redis.
+func (u *ShortenerUsecase) cache(ctx context.Context, s domain.Shortener) {
+	entry, ok := u.cacheEntry(s)
+	if !ok {
+		return
+	}
+
+	if err := u.redis.SetWithExpiration(ctx, entry.Key, entry.Value, entry.TTL); err != nil {
+		u.log.Error().Err(err).Str("code", s.ShortCode).Msg("failed to cache url in redis")
+	}
+}
+
+// cacheEntry готовит запись кэша для ссылки. TTL записи не превышает
+// оставшееся время жизни ссылки, чтобы кэш не отдавал ее после истечения
+// срока; истекшие ссылки не кэшируются. Ссылки, чье время еще не пришло, тоже
+// не кэшируются: запись из Redis отдается без проверок, и ссылка заработала
+// бы раньше active_from.
+func (u *ShortenerUsecase) cacheEntry(s domain.Shortener) (domain.CacheEntry, bool) {
+	if s.ActiveFrom != nil && time.Now().Before(*s.ActiveFrom) {
+		return domain.CacheEntry{}, false
+	}
+
+	ttl := u.ttl
+	if s.ExpiresAt != nil {
+		left := time.Until(*s.ExpiresAt)
+		if left <= 0 {
+			return domain.CacheEntry{}, false
+		}
+		if ttl <= 0 || left < ttl {
+			ttl = left
+		}
+	}
+
+	link := cachedLink{
+		URL:             s.LongURL,
+		Status:          s.Redirect().StatusCode,
+		ExpiresAt:       s.ExpiresAt,
+		QueryMode:       s.QueryMode,
+		PathPassthrough: s.PathPassthrough,
+		Targets:         s.Targets,
+		Variants:        s.Variants,
+		StickyVariants:  s.StickyVariants,
+		PasswordHash:    s.PasswordHash,
+		Title:           s.Title,
+		Interstitial:    s.InterstitialSeconds,
+		MaxClicks:       s.MaxClicks,
+	}
+	if !s.UTM.IsZero() {
+		link.UTM = &s.UTM
+	}
+	value, _ := json.Marshal(link)
+
+	return domain.CacheEntry{Key: s.ShortCode, Value: value, TTL: ttl}, true
+}
+
+func (u *ShortenerUsecase) invalidate(ctx context.Context, shortCode string) {
+	if err := u.redis.Del(ctx, shortCode); err != nil {
+		u.log.Error().Err(err).Str("code", shortCode).Msg("failed to invalidate url in redis")
+	}
+}
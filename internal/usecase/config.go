@@ -0,0 +1,39 @@
+package usecase
+
+import "time"
+
+// Config - настройки сокращения ссылок.
+type Config struct {
+	// Generator - стратегия генерации кодов: random или sequence.
+	Generator string `mapstructure:"generator" validate:"oneof=random sequence"`
+	// CodeLength - начальная длина сгенерированного кода.
+	CodeLength int `mapstructure:"code_length" validate:"min=1,max=10"`
+	// Alphabet - символы сгенерированного кода. Пусто - base62.
+	Alphabet string `mapstructure:"alphabet"`
+	// Salt - соль кодирования для генератора sequence. Смена соли меняет
+	// коды новых ссылок, но не затрагивает уже выданные.
+	Salt string `mapstructure:"salt"`
+	// MaxAttempts - сколько раз пробовать сгенерировать код при коллизиях.
+	MaxAttempts int `mapstructure:"max_attempts" validate:"min=1"`
+	// Dedup возвращает существующий код вместо новой ссылки на тот же URL
+	// того же владельца.
+	Dedup bool `mapstructure:"dedup"`
+	// UnlockAttempts - сколько попыток ввода пароля защищенной ссылки дается
+	// одному IP за UnlockWindow. 0 - значение по умолчанию.
+	UnlockAttempts int `mapstructure:"unlock_attempts"`
+	// UnlockCodeFailures - сколько неверных паролей к одной ссылке со всех
+	// IP принимается за UnlockWindow. Ограничение по IP обходится сменой
+	// адреса, это - нет. 0 - значение по умолчанию.
+	UnlockCodeFailures int `mapstructure:"unlock_code_failures"`
+	// UnlockWindow - окно подсчета попыток ввода пароля.
+	UnlockWindow time.Duration `mapstructure:"unlock_window"`
+	// FallbackURL - куда перенаправлять переходы по несуществующим и
+	// недоступным кодам. Пусто - отвечать 404.
+	FallbackURL string `mapstructure:"fallback_url" validate:"omitempty,url"`
+	// OwnerFallbackURLs - адреса для недоступных ссылок конкретных
+	// владельцев, важнее FallbackURL. Владельцы сравниваются без учета регистра.
+	OwnerFallbackURLs map[string]string `mapstructure:"owner_fallback_urls" validate:"dive,url"`
+	// UniqueVisitors включает подсчет уникальных посетителей по анонимному
+	// отпечатку клика: хэшу IP и User-Agent с солью, которая меняется раз в сутки.
+	UniqueVisitors bool `mapstructure:"unique_visitors"`
+}
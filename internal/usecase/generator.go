@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"github.com/adexcell/shortener/pkg/codegen"
+)
+
+const (
+	GeneratorRandom   = "random"
+	GeneratorSequence = "sequence"
+)
+
+// SequenceGenerator выдает коды по счетчику Postgres, пропуская его через
+// солёное биективное кодирование. Коды не повторяются, а их длина растет
+// только вместе с числом ссылок.
+type SequenceGenerator struct {
+	postgres domain.ShortenerPostgres
+	encoder  *codegen.Hashid
+}
+
+func NewSequenceGenerator(p domain.ShortenerPostgres, alphabet, salt string) (domain.CodeGenerator, error) {
+	encoder, err := codegen.NewHashid(alphabet, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SequenceGenerator{postgres: p, encoder: encoder}, nil
+}
+
+// Generate игнорирует length: длину определяет значение счетчика.
+func (g *SequenceGenerator) Generate(ctx context.Context, _ int) (string, error) {
+	id, err := g.postgres.NextID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("g.postgres.NextID: %w", err)
+	}
+
+	return g.encoder.Encode(id), nil
+}
+
+// NewGenerator создает генератор кодов, выбранный в конфиге.
+func NewGenerator(cfg Config, p domain.ShortenerPostgres) (domain.CodeGenerator, error) {
+	switch cfg.Generator {
+	case GeneratorRandom, "":
+		return codegen.NewRandom(cfg.Alphabet)
+	case GeneratorSequence:
+		return NewSequenceGenerator(p, cfg.Alphabet, cfg.Salt)
+	default:
+		return nil, fmt.Errorf("unknown code generator %q", cfg.Generator)
+	}
+}
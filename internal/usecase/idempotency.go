@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/adexcell/shortener/internal/domain"
+)
+
+// idempotencyTTL - сколько помнить ответ на запрос с Idempotency-Key.
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyRecord - то, что хранится в Redis по ключу идемпотентности.
+// Пустой ShortCode означает, что первый запрос еще выполняется.
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	ShortCode   string `json:"short_code,omitempty"`
+}
+
+// shortenIdempotent выполняет Shorten не больше одного раза на ключ: ключ
+// резервируется через SETNX до вставки, а после успеха в него записывается
+// код. Если Redis недоступен, запрос выполняется без защиты от повторов.
+func (u *ShortenerUsecase) shortenIdempotent(ctx context.Context, s domain.Shortener, idem domain.Idempotency) (string, error) {
+	key := "idempotency:" + s.OwnerID + ":" + idem.Key
+	fingerprint := idem.Fingerprint
+
+	pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+	reserved, err := u.redis.SetNX(ctx, key, pending, idempotencyTTL)
+	if err != nil {
+		u.log.Error().Err(err).Msg("failed to reserve idempotency key in redis")
+		return u.shorten(ctx, s)
+	}
+	if !reserved {
+		return u.replay(ctx, key, fingerprint)
+	}
+
+	code, err := u.shorten(ctx, s)
+	if err != nil {
+		// Ошибку не запоминаем: клиент должен иметь возможность повторить запрос.
+		if err := u.redis.Del(ctx, key); err != nil {
+			u.log.Error().Err(err).Msg("failed to release idempotency key in redis")
+		}
+		return "", err
+	}
+
+	done, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint, ShortCode: code})
+	if err := u.redis.SetWithExpiration(ctx, key, done, idempotencyTTL); err != nil {
+		u.log.Error().Err(err).Msg("failed to save idempotency record in redis")
+	}
+
+	return code, nil
+}
+
+func (u *ShortenerUsecase) replay(ctx context.Context, key, fingerprint string) (string, error) {
+	raw, err := u.redis.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	var rec idempotencyRecord
+	if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+		return "", err
+	}
+
+	if rec.Fingerprint != fingerprint {
+		return "", domain.ErrIdempotencyMismatch
+	}
+	if rec.ShortCode == "" {
+		return "", domain.ErrIdempotencyInProgress
+	}
+
+	return rec.ShortCode, nil
+}
@@ -0,0 +1,131 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adexcell/shortener/internal/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// defaultUnlockAttempts - неудачных попыток ввода пароля за окно, если
+	// в конфиге не задано иное.
+	defaultUnlockAttempts = 5
+	// defaultUnlockCodeFailures - неверных паролей к одной ссылке со всех IP
+	// за окно, если в конфиге не задано иное.
+	defaultUnlockCodeFailures = 100
+	// defaultUnlockWindow - окно, за которое считаются попытки.
+	defaultUnlockWindow = 15 * time.Minute
+)
+
+// hashPassword заменяет открытый пароль ссылки его bcrypt-хэшем.
+func hashPassword(s *domain.Shortener) error {
+	if s.Password == "" {
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(s.Password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return domain.ErrPasswordTooLong
+	}
+	if err != nil {
+		return fmt.Errorf("bcrypt.GenerateFromPassword: %w", err)
+	}
+	s.PasswordHash = string(hash)
+	s.Password = ""
+
+	return nil
+}
+
+// hashPasswords хэширует пароли ссылок пакета параллельно, не больше
+// GOMAXPROCS одновременно: bcrypt занимает десятки миллисекунд процессора, и
+// по очереди пакет защищенных ссылок не уложился бы в таймаут ответа.
+// Ошибка i-й ссылки возвращается в i-м элементе.
+func hashPasswords(links []domain.Shortener) []error {
+	errs := make([]error, len(links))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i := range links {
+		if links[i].Password == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = hashPassword(&links[i])
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// hashUpdatePassword хэширует новый пароль из изменения ссылки. Пустой
+// пароль снимает защиту и остается пустым.
+func hashUpdatePassword(upd *domain.LinkUpdate) error {
+	if upd.Password == nil {
+		return nil
+	}
+
+	link := domain.Shortener{Password: *upd.Password}
+	if err := hashPassword(&link); err != nil {
+		return err
+	}
+	upd.PasswordHash = &link.PasswordHash
+
+	return nil
+}
+
+// unlock проверяет пароль перехода по защищенной ссылке. Попытки считаются
+// в Redis по коду и IP: после UnlockAttempts попыток за окно UnlockWindow
+// пароль не проверяется до конца окна, успешный ввод сбрасывает счетчик.
+// Неверные пароли к ссылке считаются еще и со всех IP: после
+// UnlockCodeFailures ошибок за окно ссылка не принимает пароль ни от кого,
+// поэтому перебор со сменой IP упирается в тот же предел. Успешные вводы в
+// этот счетчик не попадают, и популярная ссылка им не блокируется.
+// Недоступный Redis не блокирует переходы: перебор и так замедляет bcrypt.
+func (u *ShortenerUsecase) unlock(ctx context.Context, link domain.Shortener, v domain.Visit) error {
+	if v.Password == "" {
+		return domain.ErrPasswordRequired
+	}
+
+	key := "unlock:" + link.ShortCode + ":" + v.IP
+	window := cmp.Or(u.cfg.UnlockWindow, defaultUnlockWindow)
+	limit := cmp.Or(u.cfg.UnlockAttempts, defaultUnlockAttempts)
+	attempts, err := u.redis.Incr(ctx, key, window)
+	if err != nil {
+		u.log.Error().Err(err).Str("code", link.ShortCode).Msg("failed to count unlock attempts in redis")
+	} else if attempts > int64(limit) {
+		return domain.ErrTooManyAttempts
+	}
+
+	// Промах Redis означает, что неверных паролей за окно еще не было.
+	codeKey := "unlock:" + link.ShortCode
+	if failures, err := u.redis.Get(ctx, codeKey); err == nil {
+		if n, _ := strconv.Atoi(failures); n >= cmp.Or(u.cfg.UnlockCodeFailures, defaultUnlockCodeFailures) {
+			return domain.ErrTooManyAttempts
+		}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(v.Password)) != nil {
+		if _, err := u.redis.Incr(ctx, codeKey, window); err != nil {
+			u.log.Error().Err(err).Str("code", link.ShortCode).Msg("failed to count unlock failures in redis")
+		}
+		return domain.ErrWrongPassword
+	}
+
+	if err := u.redis.Del(ctx, key); err != nil {
+		u.log.Error().Err(err).Str("code", link.ShortCode).Msg("failed to reset unlock attempts in redis")
+	}
+
+	return nil
+}
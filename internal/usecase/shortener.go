@@ -3,35 +3,59 @@ package usecase
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/adexcell/shortener/internal/domain"
 	"github.com/adexcell/shortener/pkg/log"
 	"github.com/adexcell/shortener/pkg/postgres"
+	"github.com/adexcell/shortener/pkg/utils/urlnorm"
+	"github.com/adexcell/shortener/pkg/utils/useragent"
 )
 
 type ShortenerUsecase struct {
-	log      log.Log
-	postgres domain.ShortenerPostgres
-	redis    domain.ShortenerRedis
-	ttl      time.Duration
-	statsCh  chan domain.Stats
-	wg       sync.WaitGroup
-	mu       sync.RWMutex
-	closed   bool
+	log       log.Log
+	postgres  domain.ShortenerPostgres
+	redis     domain.ShortenerRedis
+	generator domain.CodeGenerator
+	geo       domain.GeoLocator
+	cfg       Config
+	// aliases - правила алиасов: по ним ищутся коды при Lowercase
+	aliases domain.AliasPolicy
+	ttl     time.Duration
+	statsCh chan domain.Stats
+	// visitorSalt - соль отпечатков посетителей, кэш на сутки
+	visitorSalt visitorSalt
+	wg          sync.WaitGroup
+	mu          sync.RWMutex
+	closed      bool
 }
 
-func New(p domain.ShortenerPostgres, r domain.ShortenerRedis, l log.Log, t time.Duration) domain.ShortenerUsecase {
+func New(
+	p domain.ShortenerPostgres,
+	r domain.ShortenerRedis,
+	g domain.CodeGenerator,
+	geo domain.GeoLocator,
+	l log.Log,
+	t time.Duration,
+	cfg Config,
+	aliases domain.AliasPolicy,
+) domain.ShortenerUsecase {
 	u := &ShortenerUsecase{
-		log:      l,
-		postgres: p,
-		redis:    r,
-		ttl:      t,
-		statsCh:  make(chan domain.Stats, 1000),
+		log:       l,
+		postgres:  p,
+		redis:     r,
+		generator: g,
+		geo:       geo,
+		cfg:       cfg,
+		aliases:   aliases,
+		ttl:       t,
+		statsCh:   make(chan domain.Stats, 1000),
 	}
 
 	u.wg.Add(1)
@@ -41,62 +65,355 @@ func New(p domain.ShortenerPostgres, r domain.ShortenerRedis, l log.Log, t time.
 }
 
 // Shorten генерирует код и сохраняет в БД
-func (u *ShortenerUsecase) Shorten(ctx context.Context, shortCode, longURL string) (string, error) {
-	if shortCode == "" {
-		b := make([]byte, 4)
-		rand.Read(b)
-		shortCode = base64.URLEncoding.EncodeToString(b)[:6]
+func (u *ShortenerUsecase) Shorten(ctx context.Context, s domain.Shortener, idem domain.Idempotency) (string, error) {
+	s.OwnerID = domain.OwnerFromContext(ctx)
+	if err := hashPassword(&s); err != nil {
+		return "", err
 	}
 
-	err := u.postgres.Save(ctx, shortCode, longURL)
+	if idem.Key == "" {
+		return u.shorten(ctx, s)
+	}
+
+	return u.shortenIdempotent(ctx, s, idem)
+}
+
+func (u *ShortenerUsecase) shorten(ctx context.Context, s domain.Shortener) (string, error) {
+	if u.cfg.Dedup && s.Deduplicable() {
+		longURL, err := urlnorm.Normalize(s.LongURL)
+		if err != nil {
+			return "", fmt.Errorf("urlnorm.Normalize: %w", err)
+		}
+		s.LongURL = longURL
+
+		existing, err := u.postgres.FindDuplicate(ctx, s.OwnerID, s.LongURL)
+		if err == nil {
+			return existing.ShortCode, nil
+		}
+		if !errors.Is(err, domain.ErrNotFound) {
+			return "", err
+		}
+	}
+
+	var err error
+	if s.ShortCode == "" {
+		s.ShortCode, err = u.saveWithGeneratedCode(ctx, s)
+	} else {
+		err = u.postgres.Save(ctx, s)
+	}
 	if err != nil {
-		return "", postgres.PostgresErr(err)
+		if postgres.IsUniqueViolation(err) {
+			return "", domain.ErrAlreadyExists
+		}
+		return "", err
 	}
 
-	if err := u.redis.SetWithExpiration(ctx, shortCode, longURL, u.ttl); err != nil {
-		u.log.Error().Err(err).Str("code", shortCode).Msg("failed to save click analytics in redis")
+	u.cache(ctx, s)
+
+	return s.ShortCode, nil
+}
+
+// saveWithGeneratedCode сохраняет ссылку под сгенерированным кодом. При
+// коллизии пробует снова, каждый раз удлиняя код на символ, пока не упрется
+// в предел схемы.
+func (u *ShortenerUsecase) saveWithGeneratedCode(ctx context.Context, s domain.Shortener) (string, error) {
+	length := u.cfg.CodeLength
+	for range u.cfg.MaxAttempts {
+		code, err := u.generator.Generate(ctx, length)
+		if err != nil {
+			return "", fmt.Errorf("u.generator.Generate: %w", err)
+		}
+
+		s.ShortCode = code
+		err = u.postgres.Save(ctx, s)
+		if err == nil {
+			return code, nil
+		}
+		if !postgres.IsUniqueViolation(err) {
+			return "", err
+		}
+
+		u.log.Warn().Str("code", code).Msg("generated short code collision, retrying")
+		if length < domain.MaxShortCodeLength {
+			length++
+		}
 	}
 
-	return shortCode, nil
+	return "", domain.ErrCodeExhausted
 }
 
-// GetOriginal ищет полную ссылку по коду
-func (u *ShortenerUsecase) GetOriginal(ctx context.Context, shortCode, ip, userAgent string) (string, error) {
-	longURL, err := u.redis.Get(ctx, shortCode)
-	if err != nil {
-		longURL, err = u.postgres.GetLongURL(ctx, shortCode)
+// GetOriginal ищет ссылку по коду, сначала в Redis, затем в Postgres, и
+// строит редирект с учетом пути и query-параметров перехода.
+func (u *ShortenerUsecase) GetOriginal(ctx context.Context, v domain.Visit) (domain.Redirect, error) {
+	link, ok := u.cached(ctx, v.ShortCode)
+	if !ok {
+		var err error
+		link, err = u.get(ctx, v.ShortCode)
 		if err != nil {
-			return "", fmt.Errorf("failed to get long url from db: %w", err)
+			return domain.Redirect{}, fmt.Errorf("failed to get long url from db: %w", err)
+		}
+		// Код мог найтись в другом регистре: лимит, аналитика и кэш
+		// относятся к сохраненному коду.
+		v.ShortCode = link.ShortCode
+
+		if err := link.Available(time.Now()); err != nil {
+			return domain.Redirect{}, err
+		}
+
+		u.cache(ctx, link)
+	}
+
+	if link.PasswordHash != "" {
+		if err := u.unlock(ctx, link, v); err != nil {
+			return domain.Redirect{}, err
 		}
+	}
+
+	// Таргетинг и ротация выбирают адрес до проброса пути и параметров,
+	// чтобы они применялись и к выбранному URL. Правило таргетинга
+	// важнее ротации: оно отправляет в App Store, а не на случайный лендинг.
+	agent := useragent.Parse(v.UserAgent)
+	country := u.country(v.IP)
+	target, targeted := link.Target(domain.Client{
+		Platform: agent.OS,
+		Device:   agent.Device,
+		Country:  country,
+	})
+
+	var variant domain.Variant
+	if targeted {
+		link.LongURL = target
+	} else if chosen, ok := link.ChooseVariant(v.Variant, rand.IntN); ok {
+		variant = chosen
+		link.LongURL = chosen.URL
+	}
 
-		if err := u.redis.SetWithExpiration(ctx, shortCode, longURL, u.ttl); err != nil {
-			u.log.Error().Err(err).Str("code", shortCode).Msg("failed to save click analytics in redis")
+	redirect := link.Redirect()
+	destination, err := link.Destination(v)
+	if err != nil {
+		return domain.Redirect{}, err
+	}
+	redirect.URL = destination
+	redirect.Variant = variant.Name
+	redirect.StickyVariant = link.StickyVariants && variant.Name != ""
+
+	// Боты и сервисы превью ссылок тоже получают редирект, но в аналитике
+	// помечаются и по умолчанию не учитываются.
+	botName := agent.BotName
+	if botName == "" && v.Method == http.MethodHead {
+		botName = domain.BotHead
+	}
+
+	// Лимит считается атомарным UPDATE в urls, а не по analytics: клики
+	// пишутся туда воркером с задержкой, и параллельные переходы успели бы
+	// израсходовать одноразовую ссылку несколько раз. Боты лимит не
+	// расходуют, иначе сервис превью съел бы одноразовую ссылку сразу после
+	// публикации в мессенджере, но и адреса не получают: иначе HEAD-запрос
+	// или curl читали бы адрес одноразовой ссылки сколько угодно раз.
+	withheld := false
+	if link.Limited() {
+		consume := u.postgres.ConsumeClick
+		if botName != "" {
+			consume, withheld = u.clicksLeft, true
+		}
+		if err := consume(ctx, v.ShortCode); err != nil {
+			return domain.Redirect{}, err
 		}
 	}
 
 	stats := domain.Stats{
-		ShortCode: shortCode,
-		IP:        ip,
-		UserAgent: userAgent,
+		ShortCode:      v.ShortCode,
+		IP:             v.IP,
+		UserAgent:      v.UserAgent,
+		Campaign:       domain.CampaignOf(redirect.URL),
+		Country:        country,
+		Variant:        variant.Name,
+		Source:         v.Source,
+		Browser:        agent.Browser,
+		BrowserVersion: agent.Version,
+		OS:             agent.OS,
+		Device:         agent.Device,
+		Bot:            botName != "",
+		BotName:        botName,
+		Referrer:       domain.ReferrerHost(v.Referrer),
+	}
+	// Боты не посетители: без отпечатка они не попадают в HyperLogLog.
+	if u.cfg.UniqueVisitors && !stats.Bot {
+		stats.VisitorID = u.visitorID(ctx, v.IP, v.UserAgent, time.Now())
+	}
+	if withheld {
+		redirect = domain.Redirect{Varies: true, Withheld: true}
 	}
 
 	u.mu.RLock()
 	defer u.mu.RUnlock()
 	if u.closed {
-		return longURL, nil
+		return redirect, nil
 	}
 	select {
 	case u.statsCh <- stats:
 		// успешно отправили
 	default:
-		u.log.Warn().Str("code", shortCode).Msg("analytics channel full, dropping stat")
+		u.log.Warn().Str("code", v.ShortCode).Msg("analytics channel full, dropping stat")
+	}
+
+	return redirect, nil
+}
+
+// Preview возвращает ссылку для страницы предпросмотра. Ссылка читается из
+// Postgres, так как в кэше нет даты создания; клик не засчитывается.
+func (u *ShortenerUsecase) Preview(ctx context.Context, shortCode string) (domain.Shortener, error) {
+	link, err := u.get(ctx, shortCode)
+	if err != nil {
+		return domain.Shortener{}, err
+	}
+
+	if err := link.Available(time.Now()); err != nil {
+		return domain.Shortener{}, err
+	}
+
+	return link, nil
+}
+
+// Fallback выбирает адрес для перехода по несуществующему или недоступному
+// коду: адрес владельца ссылки, если он настроен, иначе общий FallbackURL.
+// Владелец ищется только при настроенных адресах владельцев.
+func (u *ShortenerUsecase) Fallback(ctx context.Context, shortCode string) (string, bool) {
+	if len(u.cfg.OwnerFallbackURLs) > 0 {
+		owner, err := u.postgres.Owner(ctx, shortCode)
+		if alias, ok := u.aliases.Fold(shortCode); ok && errors.Is(err, domain.ErrNotFound) {
+			owner, err = u.postgres.Owner(ctx, alias)
+		}
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			u.log.Error().Err(err).Str("code", shortCode).Msg("failed to get link owner for fallback")
+		}
+		// Ключи карты из конфига viper приводит к нижнему регистру.
+		if url, ok := u.cfg.OwnerFallbackURLs[strings.ToLower(owner)]; ok && owner != "" {
+			return url, true
+		}
+	}
+
+	return u.cfg.FallbackURL, u.cfg.FallbackURL != ""
+}
+
+// country определяет страну перехода. Без GeoIP-базы страна неизвестна, и
+// правила по странам просто не срабатывают.
+func (u *ShortenerUsecase) country(ip string) string {
+	if u.geo == nil {
+		return ""
+	}
+	return u.geo.Country(ip)
+}
+
+func (u *ShortenerUsecase) GetStats(ctx context.Context, shortCode string, q domain.StatsQuery) (domain.Stats, error) {
+	link, err := u.authorize(ctx, shortCode)
+	if err != nil {
+		return domain.Stats{}, err
+	}
+
+	stats, err := u.postgres.GetDetailedStats(ctx, link.ShortCode, q)
+	if err != nil {
+		return domain.Stats{}, err
+	}
+	if u.cfg.UniqueVisitors {
+		stats.Live = u.liveVisitors(ctx, link.ShortCode)
+	}
+
+	return stats, nil
+}
+
+// GetLink возвращает ссылку со всеми атрибутами, не засчитывая клик.
+func (u *ShortenerUsecase) GetLink(ctx context.Context, shortCode string) (domain.Shortener, error) {
+	return u.authorize(ctx, shortCode)
+}
+
+// UpdateLink частично обновляет ссылку и сбрасывает ее из кэша,
+// чтобы редирект сразу увидел изменения. Кэш заново заполняется чтением
+// с мастера (postgres.Get), поэтому отстающая реплика не вернет в Redis
+// старую ссылку. Изменение проверяется вместе с текущими полями ссылки:
+// например, перенос active_from за expires_at отклоняется.
+func (u *ShortenerUsecase) UpdateLink(ctx context.Context, shortCode string, upd domain.LinkUpdate) (domain.Shortener, error) {
+	current, err := u.authorize(ctx, shortCode)
+	if err != nil {
+		return domain.Shortener{}, err
+	}
+	if err := hashUpdatePassword(&upd); err != nil {
+		return domain.Shortener{}, err
+	}
+	if err := upd.ValidateFor(current, time.Now()); err != nil {
+		return domain.Shortener{}, err
+	}
+
+	link, err := u.postgres.Update(ctx, current.ShortCode, upd)
+	if err != nil {
+		return domain.Shortener{}, err
+	}
+
+	u.invalidate(ctx, current.ShortCode)
+
+	return link, nil
+}
+
+// DeleteLink мягко удаляет ссылку и сбрасывает ее из кэша.
+func (u *ShortenerUsecase) DeleteLink(ctx context.Context, shortCode string) error {
+	link, err := u.authorize(ctx, shortCode)
+	if err != nil {
+		return err
+	}
+
+	if err := u.postgres.Delete(ctx, link.ShortCode); err != nil {
+		return err
 	}
 
-	return longURL, nil
+	u.invalidate(ctx, link.ShortCode)
+
+	return nil
 }
 
-func (u *ShortenerUsecase) GetStats(ctx context.Context, shortCode string) (domain.Stats, error) {
-	return u.postgres.GetDetailedStats(ctx, shortCode)
+// clicksLeft проверяет по Postgres, что лимит переходов ссылки не
+// израсходован, ничего не списывая: в кэше счетчик кликов не хранится.
+func (u *ShortenerUsecase) clicksLeft(ctx context.Context, shortCode string) error {
+	link, err := u.postgres.Get(ctx, shortCode)
+	if err != nil {
+		return err
+	}
+	if link.Exhausted() {
+		return domain.ErrClicksExhausted
+	}
+
+	return nil
+}
+
+// get загружает ссылку из Postgres. Код, не найденный как есть, при
+// политике строчных алиасов ищется еще и в нижнем регистре: алиас "MyLink"
+// сохранен как "mylink". ShortCode найденной ссылки - сохраненный код.
+func (u *ShortenerUsecase) get(ctx context.Context, shortCode string) (domain.Shortener, error) {
+	link, err := u.postgres.Get(ctx, shortCode)
+	if alias, ok := u.aliases.Fold(shortCode); ok && errors.Is(err, domain.ErrNotFound) {
+		shortCode = alias
+		link, err = u.postgres.Get(ctx, shortCode)
+	}
+	if err != nil {
+		return domain.Shortener{}, err
+	}
+
+	link.ShortCode = shortCode
+	return link, nil
+}
+
+// authorize загружает ссылку и проверяет, что владелец из контекста имеет к
+// ней доступ. Администратору доступны все ссылки.
+func (u *ShortenerUsecase) authorize(ctx context.Context, shortCode string) (domain.Shortener, error) {
+	link, err := u.get(ctx, shortCode)
+	if err != nil {
+		return domain.Shortener{}, err
+	}
+
+	if !domain.IsAdmin(ctx) && !link.AccessibleBy(domain.OwnerFromContext(ctx)) {
+		return domain.Shortener{}, domain.ErrForbidden
+	}
+
+	return link, nil
 }
 
 func (u *ShortenerUsecase) runAnalyticsWorker() {
@@ -105,10 +422,11 @@ func (u *ShortenerUsecase) runAnalyticsWorker() {
 	u.log.Info().Msg("analytics worker started")
 
 	for stats := range u.statsCh {
-		err := u.postgres.SaveClick(context.Background(), stats.ShortCode, stats.IP, stats.UserAgent)
+		err := u.postgres.SaveClick(context.Background(), stats)
 		if err != nil {
 			u.log.Error().Err(err).Str("code", stats.ShortCode).Msg("failed to save click analytics in postgres")
 		}
+		u.countVisitor(context.Background(), stats)
 	}
 }
 
@@ -3,14 +3,22 @@ package usecase_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/adexcell/shortener/internal/domain"
 	"github.com/adexcell/shortener/internal/usecase"
+	"github.com/adexcell/shortener/pkg/codegen"
 	"github.com/adexcell/shortener/pkg/log"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // --- Mocks ---
@@ -19,27 +27,94 @@ const (
 	TTL = 24 * time.Hour
 )
 
+var cfg = usecase.Config{CodeLength: 6, MaxAttempts: 3}
+
+func newGenerator(t *testing.T) domain.CodeGenerator {
+	g, err := codegen.NewRandom("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// cacheValue - запись кэша для ссылки longURL с редиректом по умолчанию.
+func cacheValue(longURL string) []byte {
+	return []byte(`{"url":"` + longURL + `","status":302}`)
+}
+
+// stubGeo определяет страну по фиксированной таблице IP.
+type stubGeo map[string]string
+
+func (g stubGeo) Country(ip string) string {
+	return g[ip]
+}
+
+type MockGenerator struct {
+	mock.Mock
+}
+
+func (m *MockGenerator) Generate(ctx context.Context, length int) (string, error) {
+	args := m.Called(ctx, length)
+	return args.String(0), args.Error(1)
+}
+
 type MockPostgres struct {
 	mock.Mock
 }
 
-func (m *MockPostgres) Save(ctx context.Context, shortCode, longURL string) error {
-	args := m.Called(ctx, shortCode, longURL)
+func (m *MockPostgres) Save(ctx context.Context, s domain.Shortener) error {
+	args := m.Called(ctx, s)
 	return args.Error(0)
 }
 
-func (m *MockPostgres) GetLongURL(ctx context.Context, shortCode string) (string, error) {
+func (m *MockPostgres) SaveBatch(ctx context.Context, links []domain.Shortener) (map[string]bool, error) {
+	args := m.Called(ctx, links)
+	inserted, _ := args.Get(0).(map[string]bool)
+	return inserted, args.Error(1)
+}
+
+func (m *MockPostgres) Get(ctx context.Context, shortCode string) (domain.Shortener, error) {
 	args := m.Called(ctx, shortCode)
-	return args.String(0), args.Error(1)
+	return args.Get(0).(domain.Shortener), args.Error(1)
+}
+
+func (m *MockPostgres) FindDuplicate(ctx context.Context, ownerID, longURL string) (domain.Shortener, error) {
+	args := m.Called(ctx, ownerID, longURL)
+	return args.Get(0).(domain.Shortener), args.Error(1)
+}
+
+func (m *MockPostgres) Update(ctx context.Context, shortCode string, upd domain.LinkUpdate) (domain.Shortener, error) {
+	args := m.Called(ctx, shortCode, upd)
+	return args.Get(0).(domain.Shortener), args.Error(1)
 }
 
-func (m *MockPostgres) SaveClick(ctx context.Context, shortCode, ip, userAgent string) error {
-	args := m.Called(ctx, shortCode, ip, userAgent)
+func (m *MockPostgres) Delete(ctx context.Context, shortCode string) error {
+	args := m.Called(ctx, shortCode)
 	return args.Error(0)
 }
 
-func (m *MockPostgres) GetDetailedStats(ctx context.Context, shortCode string) (domain.Stats, error) {
+func (m *MockPostgres) Owner(ctx context.Context, shortCode string) (string, error) {
 	args := m.Called(ctx, shortCode)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPostgres) ConsumeClick(ctx context.Context, shortCode string) error {
+	args := m.Called(ctx, shortCode)
+	return args.Error(0)
+}
+
+func (m *MockPostgres) NextID(ctx context.Context) (uint64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (m *MockPostgres) SaveClick(ctx context.Context, click domain.Stats) error {
+	args := m.Called(ctx, click)
+	return args.Error(0)
+}
+
+func (m *MockPostgres) GetDetailedStats(ctx context.Context, shortCode string, q domain.StatsQuery) (domain.Stats, error) {
+	args := m.Called(ctx, shortCode, q)
 	return args.Get(0).(domain.Stats), args.Error(1)
 }
 
@@ -61,6 +136,36 @@ func (m *MockRedis) Get(ctx context.Context, key string) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockRedis) SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	args := m.Called(ctx, key, value, expiration)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRedis) SetMany(ctx context.Context, entries []domain.CacheEntry) error {
+	args := m.Called(ctx, entries)
+	return args.Error(0)
+}
+
+func (m *MockRedis) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	args := m.Called(ctx, key, ttl)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRedis) Del(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockRedis) PFAdd(ctx context.Context, key, member string, ttl time.Duration) error {
+	args := m.Called(ctx, key, member, ttl)
+	return args.Error(0)
+}
+
+func (m *MockRedis) PFCount(ctx context.Context, key string) (int64, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockRedis) Close() error {
 	return nil
 }
@@ -73,15 +178,15 @@ func TestShortenerUsecase_Shorten(t *testing.T) {
 	mockRedis := new(MockRedis)
 	log := log.New()
 
-	uc := usecase.New(mockPg, mockRedis, log, TTL)
+	uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log, TTL, cfg, domain.AliasPolicy{})
 	longURL := "https://example.com"
 
 	t.Run("success", func(t *testing.T) {
 		// Expectation: Save to postgres, then save to redis
-		mockPg.On("Save", ctx, mock.AnythingOfType("string"), longURL).Return(nil).Once()
-		mockRedis.On("SetWithExpiration", ctx, mock.AnythingOfType("string"), longURL, 24*time.Hour).Return(nil).Once()
+		mockPg.On("Save", ctx, mock.AnythingOfType("domain.Shortener")).Return(nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, mock.AnythingOfType("string"), cacheValue(longURL), 24*time.Hour).Return(nil).Once()
 
-		code, err := uc.Shorten(ctx, "", longURL)
+		code, err := uc.Shorten(ctx, domain.Shortener{LongURL: longURL}, domain.Idempotency{})
 
 		assert.NoError(t, err)
 		assert.NotEmpty(t, code)
@@ -91,14 +196,338 @@ func TestShortenerUsecase_Shorten(t *testing.T) {
 	})
 
 	t.Run("postgres error", func(t *testing.T) {
-		mockPg.On("Save", ctx, mock.AnythingOfType("string"), longURL).Return(errors.New("db error")).Once()
+		mockPg.On("Save", ctx, mock.AnythingOfType("domain.Shortener")).Return(errors.New("db error")).Once()
 
-		code, err := uc.Shorten(ctx, "", longURL)
+		code, err := uc.Shorten(ctx, domain.Shortener{LongURL: longURL}, domain.Idempotency{})
 
 		assert.Error(t, err)
 		assert.Empty(t, code)
 		mockPg.AssertExpectations(t)
 	})
+
+	t.Run("alias already taken", func(t *testing.T) {
+		mockPg.On("Save", ctx, mock.AnythingOfType("domain.Shortener")).Return(&pq.Error{Code: "23505"}).Once()
+
+		code, err := uc.Shorten(ctx, domain.Shortener{ShortCode: "taken", LongURL: longURL}, domain.Idempotency{})
+
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+		assert.Empty(t, code)
+		mockPg.AssertExpectations(t)
+	})
+
+	t.Run("cache ttl capped by expiration", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+
+		mockPg.On("Save", ctx, mock.AnythingOfType("domain.Shortener")).Return(nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, "promo", mock.Anything, mock.MatchedBy(func(ttl time.Duration) bool {
+			return ttl > 0 && ttl <= time.Hour
+		})).Return(nil).Once()
+
+		code, err := uc.Shorten(ctx, domain.Shortener{ShortCode: "promo", LongURL: longURL, ExpiresAt: &expiresAt}, domain.Idempotency{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "promo", code)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+}
+
+func TestShortenerUsecase_ShortenCollisions(t *testing.T) {
+	ctx := context.Background()
+	longURL := "https://example.com"
+	collision := &pq.Error{Code: "23505"}
+
+	t.Run("retry with longer code", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		mockGen := new(MockGenerator)
+		uc := usecase.New(mockPg, mockRedis, mockGen, nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockGen.On("Generate", ctx, 6).Return("aaaaaa", nil).Once()
+		mockGen.On("Generate", ctx, 7).Return("bbbbbbb", nil).Once()
+		mockPg.On("Save", ctx, mock.MatchedBy(func(s domain.Shortener) bool { return s.ShortCode == "aaaaaa" })).Return(collision).Once()
+		mockPg.On("Save", ctx, mock.MatchedBy(func(s domain.Shortener) bool { return s.ShortCode == "bbbbbbb" })).Return(nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, "bbbbbbb", cacheValue(longURL), TTL).Return(nil).Once()
+
+		code, err := uc.Shorten(ctx, domain.Shortener{LongURL: longURL}, domain.Idempotency{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "bbbbbbb", code)
+		mockGen.AssertExpectations(t)
+		mockPg.AssertExpectations(t)
+	})
+
+	t.Run("give up after max attempts", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		mockGen := new(MockGenerator)
+		uc := usecase.New(mockPg, mockRedis, mockGen, nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockGen.On("Generate", ctx, mock.AnythingOfType("int")).Return("dup", nil).Times(cfg.MaxAttempts)
+		mockPg.On("Save", ctx, mock.AnythingOfType("domain.Shortener")).Return(collision).Times(cfg.MaxAttempts)
+
+		code, err := uc.Shorten(ctx, domain.Shortener{LongURL: longURL}, domain.Idempotency{})
+
+		assert.ErrorIs(t, err, domain.ErrCodeExhausted)
+		assert.Empty(t, code)
+		mockGen.AssertExpectations(t)
+		mockPg.AssertExpectations(t)
+	})
+}
+
+func TestShortenerUsecase_ShortenBatch(t *testing.T) {
+	ctx := context.Background()
+	collision := map[string]bool{}
+
+	t.Run("per item results", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		mockGen := new(MockGenerator)
+		uc := usecase.New(mockPg, mockRedis, mockGen, nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockGen.On("Generate", ctx, 6).Return("aaaaaa", nil).Once()
+		mockGen.On("Generate", ctx, 7).Return("bbbbbbb", nil).Once()
+		mockPg.On("SaveBatch", ctx, mock.MatchedBy(func(links []domain.Shortener) bool { return len(links) == 3 })).
+			Return(map[string]bool{"promo": true}, nil).Once()
+		mockPg.On("SaveBatch", ctx, mock.MatchedBy(func(links []domain.Shortener) bool {
+			return len(links) == 1 && links[0].ShortCode == "bbbbbbb"
+		})).Return(map[string]bool{"bbbbbbb": true}, nil).Once()
+		mockRedis.On("SetMany", ctx, []domain.CacheEntry{
+			{Key: "promo", Value: cacheValue("https://a.example"), TTL: TTL},
+			{Key: "bbbbbbb", Value: cacheValue("https://c.example"), TTL: TTL},
+		}).Return(nil).Once()
+
+		results, err := uc.ShortenBatch(ctx, []domain.Shortener{
+			{ShortCode: "promo", LongURL: "https://a.example"},
+			{ShortCode: "taken", LongURL: "https://b.example"},
+			{LongURL: "https://c.example"},
+			{ShortCode: "promo", LongURL: "https://d.example"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.BatchResult{
+			{ShortCode: "promo"},
+			{Err: domain.ErrAlreadyExists},
+			{ShortCode: "bbbbbbb"},
+			{Err: domain.ErrAlreadyExists},
+		}, results)
+		mockGen.AssertExpectations(t)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("password protected items", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		var saved []domain.Shortener
+		mockPg.On("SaveBatch", ctx, mock.AnythingOfType("[]domain.Shortener")).Run(func(args mock.Arguments) {
+			saved = args.Get(1).([]domain.Shortener)
+		}).Return(map[string]bool{"secret": true, "public": true}, nil).Once()
+		mockRedis.On("SetMany", ctx, mock.Anything).Return(nil).Once()
+
+		results, err := uc.ShortenBatch(ctx, []domain.Shortener{
+			{ShortCode: "secret", LongURL: "https://a.example", Password: "hunter2"},
+			{ShortCode: "public", LongURL: "https://b.example"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.BatchResult{{ShortCode: "secret"}, {ShortCode: "public"}}, results)
+		if assert.Len(t, saved, 2) {
+			assert.Empty(t, saved[0].Password)
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(saved[0].PasswordHash), []byte("hunter2")))
+			assert.Empty(t, saved[1].PasswordHash)
+		}
+		mockPg.AssertExpectations(t)
+	})
+
+	t.Run("many password protected items", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		links := make([]domain.Shortener, 20)
+		inserted := make(map[string]bool, len(links))
+		for i := range links {
+			code := fmt.Sprintf("secret%d", i)
+			links[i] = domain.Shortener{ShortCode: code, LongURL: "https://a.example", Password: "hunter" + strconv.Itoa(i)}
+			inserted[code] = true
+		}
+
+		var saved []domain.Shortener
+		mockPg.On("SaveBatch", ctx, mock.AnythingOfType("[]domain.Shortener")).Run(func(args mock.Arguments) {
+			saved = args.Get(1).([]domain.Shortener)
+		}).Return(inserted, nil).Once()
+		mockRedis.On("SetMany", ctx, mock.Anything).Return(nil).Once()
+
+		results, err := uc.ShortenBatch(ctx, links)
+
+		assert.NoError(t, err)
+		if assert.Len(t, saved, len(links)) {
+			for i, s := range saved {
+				assert.NoError(t, results[i].Err)
+				assert.Empty(t, s.Password)
+				assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(s.PasswordHash), []byte("hunter"+strconv.Itoa(i))), s.ShortCode)
+			}
+		}
+		mockPg.AssertExpectations(t)
+	})
+
+	t.Run("password too long for bcrypt", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockPg.On("SaveBatch", ctx, mock.AnythingOfType("[]domain.Shortener")).Return(map[string]bool{"public": true}, nil).Once()
+		mockRedis.On("SetMany", ctx, mock.Anything).Return(nil).Once()
+
+		results, err := uc.ShortenBatch(ctx, []domain.Shortener{
+			{ShortCode: "secret", LongURL: "https://a.example", Password: strings.Repeat("п", 40)},
+			{ShortCode: "public", LongURL: "https://b.example"},
+		})
+
+		assert.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, domain.ErrPasswordTooLong)
+		assert.Equal(t, domain.BatchResult{ShortCode: "public"}, results[1])
+		mockPg.AssertExpectations(t)
+	})
+
+	t.Run("generated codes exhausted", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		mockGen := new(MockGenerator)
+		uc := usecase.New(mockPg, mockRedis, mockGen, nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockGen.On("Generate", ctx, 6).Return("aaaaaa", nil).Once()
+		mockGen.On("Generate", ctx, 7).Return("bbbbbbb", nil).Once()
+		mockGen.On("Generate", ctx, 8).Return("cccccccc", nil).Once()
+		mockPg.On("SaveBatch", ctx, mock.Anything).Return(collision, nil).Times(cfg.MaxAttempts)
+
+		results, err := uc.ShortenBatch(ctx, []domain.Shortener{{LongURL: "https://example.com"}})
+
+		assert.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, domain.ErrCodeExhausted)
+		mockRedis.AssertNotCalled(t, "SetMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("postgres error fails batch", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		uc := usecase.New(mockPg, new(MockRedis), newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockPg.On("SaveBatch", ctx, mock.Anything).Return(nil, errors.New("db error")).Once()
+
+		results, err := uc.ShortenBatch(ctx, []domain.Shortener{{ShortCode: "promo", LongURL: "https://example.com"}})
+
+		assert.Error(t, err)
+		assert.Nil(t, results)
+	})
+}
+
+func TestShortenerUsecase_ShortenDedup(t *testing.T) {
+	ctx := context.Background()
+	mockPg := new(MockPostgres)
+	mockRedis := new(MockRedis)
+	dedupCfg := cfg
+	dedupCfg.Dedup = true
+	uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, dedupCfg, domain.AliasPolicy{})
+
+	t.Run("existing link is returned", func(t *testing.T) {
+		mockPg.On("FindDuplicate", ctx, "", "https://example.com/").Return(domain.Shortener{ShortCode: "exists"}, nil).Once()
+
+		code, err := uc.Shorten(ctx, domain.Shortener{LongURL: "HTTPS://Example.com"}, domain.Idempotency{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "exists", code)
+		mockPg.AssertExpectations(t)
+		mockPg.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("alias skips dedup", func(t *testing.T) {
+		mockPg.On("Save", ctx, mock.AnythingOfType("domain.Shortener")).Return(nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, "mine", cacheValue("https://example.com"), TTL).Return(nil).Once()
+
+		code, err := uc.Shorten(ctx, domain.Shortener{ShortCode: "mine", LongURL: "https://example.com"}, domain.Idempotency{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "mine", code)
+		mockPg.AssertExpectations(t)
+	})
+}
+
+func TestShortenerUsecase_ShortenIdempotent(t *testing.T) {
+	ctx := context.Background()
+	longURL := "https://example.com"
+	idem := domain.Idempotency{Key: "retry-1", Fingerprint: "body-hash"}
+	redisKey := "idempotency::retry-1"
+
+	t.Run("first request reserves key and stores code", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockRedis.On("SetNX", ctx, redisKey, mock.Anything, mock.Anything).Return(true, nil).Once()
+		mockPg.On("Save", ctx, mock.AnythingOfType("domain.Shortener")).Return(nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, "promo", cacheValue(longURL), TTL).Return(nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, redisKey, mock.MatchedBy(func(v []byte) bool {
+			return strings.Contains(string(v), `"short_code":"promo"`)
+		}), mock.Anything).Return(nil).Once()
+
+		code, err := uc.Shorten(ctx, domain.Shortener{ShortCode: "promo", LongURL: longURL}, idem)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "promo", code)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("retry replays code", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockRedis.On("SetNX", ctx, redisKey, mock.Anything, mock.Anything).Return(false, nil).Once()
+		mockRedis.On("Get", ctx, redisKey).Return(`{"fingerprint":"body-hash","short_code":"promo"}`, nil).Once()
+
+		code, err := uc.Shorten(ctx, domain.Shortener{ShortCode: "promo", LongURL: longURL}, idem)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "promo", code)
+		mockPg.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("same key with another body", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockRedis.On("SetNX", ctx, redisKey, mock.Anything, mock.Anything).Return(false, nil).Once()
+		mockRedis.On("Get", ctx, redisKey).Return(`{"fingerprint":"other","short_code":"promo"}`, nil).Once()
+
+		_, err := uc.Shorten(ctx, domain.Shortener{ShortCode: "promo", LongURL: longURL}, idem)
+
+		assert.ErrorIs(t, err, domain.ErrIdempotencyMismatch)
+	})
+}
+
+func TestSequenceGenerator(t *testing.T) {
+	ctx := context.Background()
+	mockPg := new(MockPostgres)
+
+	g, err := usecase.NewGenerator(usecase.Config{Generator: usecase.GeneratorSequence, Salt: "pepper"}, mockPg)
+	assert.NoError(t, err)
+
+	mockPg.On("NextID", ctx).Return(uint64(1000), nil).Once()
+	mockPg.On("NextID", ctx).Return(uint64(1001), nil).Once()
+
+	first, err := g.Generate(ctx, 6)
+	assert.NoError(t, err)
+	second, err := g.Generate(ctx, 6)
+	assert.NoError(t, err)
+
+	assert.Len(t, first, 3)
+	assert.NotEqual(t, first, second)
+	mockPg.AssertExpectations(t)
 }
 
 func TestShortenerUsecase_GetOriginal(t *testing.T) {
@@ -107,14 +536,15 @@ func TestShortenerUsecase_GetOriginal(t *testing.T) {
 	log := log.New()
 	ctx := context.Background()
 
-	uc := usecase.New(mockPg, mockRedis, log, TTL)
+	uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log, TTL, cfg, domain.AliasPolicy{})
 	shortCode := "abcdef"
 	longURL := "https://example.com"
 	ip := "127.0.0.1"
 	ua := "Go-Test"
+	visit := domain.Visit{ShortCode: shortCode, IP: ip, UserAgent: ua}
 
 	t.Run("redis hit", func(t *testing.T) {
-		mockRedis.On("Get", ctx, shortCode).Return(longURL, nil).Once()
+		mockRedis.On("Get", ctx, shortCode).Return(`{"url":"`+longURL+`","status":301}`, nil).Once()
 		// SaveClick is called in a goroutine, so we might not be able to assert it reliably without sync.
 		// However, the mock might catch it if we add a wait or sleep, but for unit test speed we often ignore async calls or just mock them loosely.
 		// For this test, we accept if it's called or not, BUT mocks are strict.
@@ -122,38 +552,950 @@ func TestShortenerUsecase_GetOriginal(t *testing.T) {
 		// Since it's async context.Background(), the mocking might happen after test finishes.
 		// We'll define it as .Maybe() or use a separate mock instance if we wanted strict async testing.
 		// Let's use .Maybe() and .Return(nil) to prevent panic if it is called.
-		mockPg.On("SaveClick", mock.Anything, shortCode, ip, ua).Return(nil).Maybe()
+		mockPg.On("SaveClick", mock.Anything, domain.Stats{ShortCode: shortCode, IP: ip, UserAgent: ua}).Return(nil).Maybe()
 
-		url, err := uc.GetOriginal(ctx, shortCode, ip, ua)
+		redirect, err := uc.GetOriginal(ctx, visit)
 
 		assert.NoError(t, err)
-		assert.Equal(t, longURL, url)
+		assert.Equal(t, domain.Redirect{URL: longURL, StatusCode: 301}, redirect)
 		mockRedis.AssertExpectations(t)
 	})
 
 	t.Run("redis miss, postgres hit", func(t *testing.T) {
 		mockRedis.On("Get", ctx, shortCode).Return("", errors.New("not found")).Once()
-		mockPg.On("GetLongURL", ctx, shortCode).Return(longURL, nil).Once()
-		mockRedis.On("SetWithExpiration", ctx, shortCode, longURL, 24*time.Hour).Return(nil).Once()
-		mockPg.On("SaveClick", mock.Anything, shortCode, ip, ua).Return(nil).Maybe()
+		mockPg.On("Get", ctx, shortCode).Return(domain.Shortener{ShortCode: shortCode, LongURL: longURL}, nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, shortCode, cacheValue(longURL), 24*time.Hour).Return(nil).Once()
+		mockPg.On("SaveClick", mock.Anything, domain.Stats{ShortCode: shortCode, IP: ip, UserAgent: ua}).Return(nil).Maybe()
 
-		url, err := uc.GetOriginal(ctx, shortCode, ip, ua)
+		redirect, err := uc.GetOriginal(ctx, visit)
 
 		assert.NoError(t, err)
-		assert.Equal(t, longURL, url)
+		assert.Equal(t, domain.Redirect{URL: longURL, StatusCode: 302}, redirect)
 		mockPg.AssertExpectations(t)
 		mockRedis.AssertExpectations(t)
 	})
 
 	t.Run("not found everywhere", func(t *testing.T) {
 		mockRedis.On("Get", ctx, shortCode).Return("", errors.New("not found")).Once()
-		mockPg.On("GetLongURL", ctx, shortCode).Return("", errors.New("not found")).Once()
+		mockPg.On("Get", ctx, shortCode).Return(domain.Shortener{}, errors.New("not found")).Once()
 
-		url, err := uc.GetOriginal(ctx, shortCode, ip, ua)
+		redirect, err := uc.GetOriginal(ctx, visit)
 
 		assert.Error(t, err) // The usecase returns an error when URL is not found
-		assert.Empty(t, url)
+		assert.Empty(t, redirect)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Minute)
+
+		mockRedis.On("Get", ctx, shortCode).Return("", errors.New("not found")).Once()
+		mockPg.On("Get", ctx, shortCode).Return(domain.Shortener{ShortCode: shortCode, LongURL: longURL, ExpiresAt: &expiresAt}, nil).Once()
+
+		redirect, err := uc.GetOriginal(ctx, visit)
+
+		assert.ErrorIs(t, err, domain.ErrExpired)
+		assert.Empty(t, redirect)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mockRedis.On("Get", ctx, shortCode).Return("", errors.New("not found")).Once()
+		mockPg.On("Get", ctx, shortCode).Return(domain.Shortener{ShortCode: shortCode, LongURL: longURL, Disabled: true}, nil).Once()
+
+		redirect, err := uc.GetOriginal(ctx, visit)
+
+		assert.ErrorIs(t, err, domain.ErrDisabled)
+		assert.Empty(t, redirect)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+}
+
+func TestShortenerUsecase_LowercaseAliases(t *testing.T) {
+	mockPg := new(MockPostgres)
+	mockRedis := new(MockRedis)
+	ctx := context.Background()
+
+	uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.NewAliasPolicy(true, nil))
+	longURL := "https://example.com"
+	savedAs := func(code string) any {
+		return mock.MatchedBy(func(s domain.Stats) bool { return s.ShortCode == code })
+	}
+
+	t.Run("alias in other case", func(t *testing.T) {
+		mockRedis.On("Get", ctx, "MyLink").Return("", errors.New("not found")).Once()
+		mockPg.On("Get", ctx, "MyLink").Return(domain.Shortener{}, domain.ErrNotFound).Once()
+		mockPg.On("Get", ctx, "mylink").Return(domain.Shortener{ShortCode: "mylink", LongURL: longURL}, nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, "mylink", cacheValue(longURL), 24*time.Hour).Return(nil).Once()
+		mockPg.On("SaveClick", mock.Anything, savedAs("mylink")).Return(nil).Maybe()
+
+		redirect, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "MyLink"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, longURL, redirect.URL)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("generated code keeps case", func(t *testing.T) {
+		mockRedis.On("Get", ctx, "aB3xY").Return("", errors.New("not found")).Once()
+		mockPg.On("Get", ctx, "aB3xY").Return(domain.Shortener{ShortCode: "aB3xY", LongURL: longURL}, nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, "aB3xY", cacheValue(longURL), 24*time.Hour).Return(nil).Once()
+		mockPg.On("SaveClick", mock.Anything, savedAs("aB3xY")).Return(nil).Maybe()
+
+		redirect, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "aB3xY"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, longURL, redirect.URL)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("manage alias in other case", func(t *testing.T) {
+		ctx := domain.WithOwner(ctx, "marketing")
+		mockPg.On("Get", ctx, "MyLink").Return(domain.Shortener{}, domain.ErrNotFound).Once()
+		mockPg.On("Get", ctx, "mylink").Return(domain.Shortener{ShortCode: "mylink", OwnerID: "marketing"}, nil).Once()
+		mockPg.On("Delete", ctx, "mylink").Return(nil).Once()
+		mockRedis.On("Del", ctx, "mylink").Return(nil).Once()
+
+		err := uc.DeleteLink(ctx, "MyLink")
+
+		assert.NoError(t, err)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("not found in any case", func(t *testing.T) {
+		mockRedis.On("Get", ctx, "NoSuch").Return("", errors.New("not found")).Once()
+		mockPg.On("Get", ctx, "NoSuch").Return(domain.Shortener{}, domain.ErrNotFound).Once()
+		mockPg.On("Get", ctx, "nosuch").Return(domain.Shortener{}, domain.ErrNotFound).Once()
+
+		_, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "NoSuch"})
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
 		mockPg.AssertExpectations(t)
 		mockRedis.AssertExpectations(t)
 	})
 }
+
+func TestShortenerUsecase_GetOriginalPassthrough(t *testing.T) {
+	ctx := context.Background()
+	longURL := "https://shop.example/catalog?ref=stored&id=1"
+
+	tests := []struct {
+		name    string
+		link    domain.Shortener
+		path    string
+		query   url.Values
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "query dropped by default",
+			link:  domain.Shortener{LongURL: longURL},
+			query: url.Values{"utm_source": {"mail"}},
+			want:  longURL,
+		},
+		{
+			name:  "keep stored values",
+			link:  domain.Shortener{LongURL: longURL, QueryMode: domain.QueryKeep},
+			query: url.Values{"ref": {"partner"}, "utm_source": {"mail"}},
+			want:  "https://shop.example/catalog?id=1&ref=stored&utm_source=mail",
+		},
+		{
+			name:  "override stored values",
+			link:  domain.Shortener{LongURL: longURL, QueryMode: domain.QueryOverride},
+			query: url.Values{"ref": {"partner"}},
+			want:  "https://shop.example/catalog?id=1&ref=partner",
+		},
+		{
+			name:  "append values",
+			link:  domain.Shortener{LongURL: longURL, QueryMode: domain.QueryAppend},
+			query: url.Values{"ref": {"partner"}},
+			want:  "https://shop.example/catalog?id=1&ref=stored&ref=partner",
+		},
+		{
+			name: "path passthrough",
+			link: domain.Shortener{LongURL: "https://shop.example/catalog/", PathPassthrough: true},
+			path: "/shoes/../../admin",
+			want: "https://shop.example/catalog/admin",
+		},
+		{
+			name:  "utm template",
+			link:  domain.Shortener{LongURL: longURL, UTM: domain.UTM{Source: "mail", Campaign: "spring"}},
+			query: url.Values{"utm_campaign": {"partner"}},
+			want:  "https://shop.example/catalog?id=1&ref=stored&utm_campaign=spring&utm_source=mail",
+		},
+		{
+			name:  "utm template overridden by visit",
+			link:  domain.Shortener{LongURL: longURL, QueryMode: domain.QueryOverride, UTM: domain.UTM{Campaign: "spring"}},
+			query: url.Values{"utm_campaign": {"partner"}},
+			want:  "https://shop.example/catalog?id=1&ref=stored&utm_campaign=partner",
+		},
+		{
+			name:    "path without passthrough",
+			link:    domain.Shortener{LongURL: longURL},
+			path:    "/extra",
+			wantErr: domain.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPg := new(MockPostgres)
+			mockRedis := new(MockRedis)
+			uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+			defer uc.Close()
+
+			tt.link.ShortCode = "abc"
+			mockRedis.On("Get", ctx, "abc").Return("", errors.New("not found")).Once()
+			mockPg.On("Get", ctx, "abc").Return(tt.link, nil).Once()
+			mockRedis.On("SetWithExpiration", ctx, "abc", mock.Anything, TTL).Return(nil).Once()
+			mockPg.On("SaveClick", mock.Anything, mock.AnythingOfType("domain.Stats")).Return(nil).Maybe()
+
+			redirect, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "abc", Path: tt.path, Query: tt.query})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, redirect.URL)
+		})
+	}
+}
+
+func TestShortenerUsecase_GetOriginalTargeting(t *testing.T) {
+	ctx := context.Background()
+	cached := `{"url":"https://app.example","status":302,"targets":[` +
+		`{"Platform":"ios","URL":"https://apps.apple.com/app/id1"},` +
+		`{"Platform":"android","URL":"https://play.google.com/store/apps/details?id=app"}]}`
+
+	tests := map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148":           "https://apps.apple.com/app/id1",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) Chrome/120.0.0.0 Mobile Safari/537.36": "https://play.google.com/store/apps/details?id=app",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0 Safari/537.36":       "https://app.example",
+		"": "https://app.example",
+	}
+
+	for ua, want := range tests {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockRedis.On("Get", ctx, "app").Return(cached, nil).Once()
+		mockPg.On("SaveClick", mock.Anything, mock.AnythingOfType("domain.Stats")).Return(nil).Maybe()
+
+		redirect, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "app", UserAgent: ua})
+
+		assert.NoError(t, err, ua)
+		assert.Equal(t, want, redirect.URL, ua)
+		assert.NoError(t, uc.Close())
+	}
+}
+
+func TestShortenerUsecase_GetOriginalGeo(t *testing.T) {
+	ctx := context.Background()
+	geo := stubGeo{"81.2.69.142": "GB"}
+	cached := `{"url":"https://shop.example","status":302,"targets":[{"Country":"GB","URL":"https://shop.example/uk"}]}`
+
+	for ip, want := range map[string]string{
+		"81.2.69.142": "https://shop.example/uk",
+		"127.0.0.1":   "https://shop.example",
+	} {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), geo, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockRedis.On("Get", ctx, "shop").Return(cached, nil).Once()
+		mockPg.On("SaveClick", mock.Anything, mock.MatchedBy(func(click domain.Stats) bool {
+			return click.Country == geo[ip]
+		})).Return(nil).Once()
+
+		redirect, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "shop", IP: ip})
+
+		assert.NoError(t, err, ip)
+		assert.Equal(t, want, redirect.URL, ip)
+		assert.NoError(t, uc.Close())
+		mockPg.AssertExpectations(t)
+	}
+}
+
+func TestShortenerUsecase_ClickCampaign(t *testing.T) {
+	ctx := context.Background()
+	mockPg := new(MockPostgres)
+	mockRedis := new(MockRedis)
+	uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+	mockRedis.On("Get", ctx, "promo").Return(`{"url":"https://shop.example","status":302,"utm":{"Campaign":"spring"}}`, nil).Once()
+	mockPg.On("SaveClick", mock.Anything, mock.MatchedBy(func(click domain.Stats) bool {
+		return click.ShortCode == "promo" && click.Campaign == "spring" && click.Source == domain.SourceQR
+	})).Return(nil).Once()
+
+	redirect, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "promo", IP: "127.0.0.1", Source: domain.SourceQR})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://shop.example?utm_campaign=spring", redirect.URL)
+
+	// Close дожидается, пока воркер аналитики сохранит клик.
+	assert.NoError(t, uc.Close())
+	mockPg.AssertExpectations(t)
+}
+
+func TestShortenerUsecase_ClickAgent(t *testing.T) {
+	ctx := context.Background()
+	mockPg := new(MockPostgres)
+	mockRedis := new(MockRedis)
+	uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+	ua := "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.43 Mobile Safari/537.36"
+	mockRedis.On("Get", ctx, "promo").Return(`{"url":"https://shop.example","status":302}`, nil).Once()
+	mockPg.On("SaveClick", mock.Anything, mock.MatchedBy(func(click domain.Stats) bool {
+		return click.UserAgent == ua && click.Browser == "chrome" && click.BrowserVersion == "120" &&
+			click.OS == "android" && click.Device == "mobile" && !click.Bot
+	})).Return(nil).Once()
+
+	_, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "promo", IP: "127.0.0.1", UserAgent: ua})
+	assert.NoError(t, err)
+
+	assert.NoError(t, uc.Close())
+	mockPg.AssertExpectations(t)
+}
+
+func TestShortenerUsecase_ClickReferrer(t *testing.T) {
+	ctx := context.Background()
+	referrers := map[string]string{
+		"https://www.Google.com/search?q=shortener": "google.com",
+		"http://t.co/abc":                       "t.co",
+		"android-app://org.telegram.messenger/": "",
+		"not a url":                             "",
+		"":                                      "",
+	}
+
+	for referrer, want := range referrers {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockRedis.On("Get", ctx, "promo").Return(`{"url":"https://shop.example","status":302}`, nil).Once()
+		mockPg.On("SaveClick", mock.Anything, mock.MatchedBy(func(click domain.Stats) bool {
+			return click.Referrer == want
+		})).Return(nil).Once()
+
+		_, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "promo", IP: "127.0.0.1", Referrer: referrer})
+		assert.NoError(t, err, referrer)
+
+		assert.NoError(t, uc.Close())
+		mockPg.AssertExpectations(t)
+	}
+}
+
+func TestShortenerUsecase_ClickBot(t *testing.T) {
+	ctx := context.Background()
+	visitorsCfg := cfg
+	visitorsCfg.UniqueVisitors = true
+	tests := map[string]struct {
+		visit   domain.Visit
+		wantBot string
+	}{
+		"link unfurler": {
+			visit:   domain.Visit{UserAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", Method: http.MethodGet},
+			wantBot: "slack",
+		},
+		"crawler head request": {
+			visit:   domain.Visit{UserAgent: "Mozilla/5.0 (compatible; Googlebot/2.1)", Method: http.MethodHead},
+			wantBot: "google",
+		},
+		"browser head request": {
+			visit:   domain.Visit{UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0", Method: http.MethodHead},
+			wantBot: domain.BotHead,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockPg := new(MockPostgres)
+			mockRedis := new(MockRedis)
+			uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, visitorsCfg, domain.AliasPolicy{})
+
+			// Боты не получают отпечаток: ни соли, ни PFAdd в Redis.
+			mockRedis.On("Get", ctx, "promo").Return(`{"url":"https://shop.example","status":302}`, nil).Once()
+			mockPg.On("SaveClick", mock.Anything, mock.MatchedBy(func(click domain.Stats) bool {
+				return click.Bot && click.BotName == tt.wantBot && click.VisitorID == ""
+			})).Return(nil).Once()
+
+			v := tt.visit
+			v.ShortCode = "promo"
+			v.IP = "127.0.0.1"
+			_, err := uc.GetOriginal(ctx, v)
+			assert.NoError(t, err)
+
+			assert.NoError(t, uc.Close())
+			mockPg.AssertExpectations(t)
+			mockRedis.AssertExpectations(t)
+		})
+	}
+}
+
+func TestShortenerUsecase_UniqueVisitors(t *testing.T) {
+	ctx := context.Background()
+	visitorsCfg := cfg
+	visitorsCfg.UniqueVisitors = true
+	day := time.Now().UTC().Format(time.DateOnly)
+
+	mockPg := new(MockPostgres)
+	mockRedis := new(MockRedis)
+	uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, visitorsCfg, domain.AliasPolicy{})
+
+	// Соль суток читается из Redis один раз: другой экземпляр уже записал свою.
+	mockRedis.On("SetNX", ctx, "visitor_salt:"+day, mock.Anything, 48*time.Hour).Return(false, nil).Once()
+	mockRedis.On("Get", ctx, "visitor_salt:"+day).Return("shared-salt", nil).Once()
+	mockRedis.On("Get", ctx, "promo").Return(`{"url":"https://shop.example","status":302}`, nil).Times(3)
+
+	var ids []string
+	mockPg.On("SaveClick", mock.Anything, mock.AnythingOfType("domain.Stats")).Run(func(args mock.Arguments) {
+		ids = append(ids, args.Get(1).(domain.Stats).VisitorID)
+	}).Return(nil).Times(3)
+	mockRedis.On("PFAdd", mock.Anything, "uv:promo:"+day, mock.Anything, 48*time.Hour).Return(nil).Times(3)
+	mockRedis.On("PFAdd", mock.Anything, "uv:promo", mock.Anything, 90*24*time.Hour).Return(nil).Times(3)
+
+	for _, v := range []domain.Visit{
+		{ShortCode: "promo", IP: "10.0.0.1", UserAgent: "Firefox"},
+		{ShortCode: "promo", IP: "10.0.0.1", UserAgent: "Firefox"},
+		{ShortCode: "promo", IP: "10.0.0.2", UserAgent: "Firefox"},
+	} {
+		_, err := uc.GetOriginal(ctx, v)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, uc.Close())
+
+	if assert.Len(t, ids, 3) {
+		assert.Equal(t, ids[0], ids[1])
+		assert.NotEqual(t, ids[0], ids[2])
+		// Отпечаток не содержит IP в открытом виде.
+		assert.NotContains(t, ids[0], "10.0.0.1")
+	}
+	mockPg.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+
+	t.Run("live visitors in stats", func(t *testing.T) {
+		q, err := domain.NewStatsQuery("", "", "", "", time.Now())
+		assert.NoError(t, err)
+		ctx := domain.WithOwner(ctx, "marketing")
+		mockPg.On("Get", ctx, "promo").Return(domain.Shortener{ShortCode: "promo", OwnerID: "marketing"}, nil).Once()
+		mockPg.On("GetDetailedStats", ctx, "promo", q).Return(domain.Stats{TotalClicks: 3, DailyUniqueVisitors: 2}, nil).Once()
+		mockRedis.On("PFCount", ctx, "uv:promo:"+day).Return(int64(2), nil).Once()
+		mockRedis.On("PFCount", ctx, "uv:promo").Return(int64(5), nil).Once()
+
+		stats, err := uc.GetStats(ctx, "promo", q)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, stats.DailyUniqueVisitors)
+		assert.Equal(t, &domain.LiveVisitors{Today: 2, DailyTotal: 5}, stats.Live)
+	})
+
+	t.Run("redis unavailable", func(t *testing.T) {
+		q, err := domain.NewStatsQuery("", "", "", "", time.Now())
+		assert.NoError(t, err)
+		ctx := domain.WithOwner(ctx, "marketing")
+		mockPg.On("Get", ctx, "promo").Return(domain.Shortener{ShortCode: "promo", OwnerID: "marketing"}, nil).Once()
+		mockPg.On("GetDetailedStats", ctx, "promo", q).Return(domain.Stats{TotalClicks: 3, DailyUniqueVisitors: 2}, nil).Once()
+		mockRedis.On("PFCount", ctx, "uv:promo:"+day).Return(int64(0), errors.New("connection refused")).Once()
+
+		stats, err := uc.GetStats(ctx, "promo", q)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, stats.DailyUniqueVisitors)
+		assert.Nil(t, stats.Live)
+	})
+}
+
+func TestShortenerUsecase_GetOriginalVariants(t *testing.T) {
+	ctx := context.Background()
+	cached := `{"url":"https://landing.example","status":302,"sticky_variants":true,"variants":[` +
+		`{"Name":"a","URL":"https://landing.example/a","Weight":70},` +
+		`{"Name":"b","URL":"https://landing.example/b","Weight":30}]}`
+	urls := map[string]string{
+		"a": "https://landing.example/a",
+		"b": "https://landing.example/b",
+	}
+
+	tests := []struct {
+		name   string
+		sticky string
+		want   string
+	}{
+		{name: "sticky variant", sticky: "b", want: "b"},
+		{name: "removed sticky variant", sticky: "c"},
+		{name: "new visitor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPg := new(MockPostgres)
+			mockRedis := new(MockRedis)
+			uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+			var served string
+			mockRedis.On("Get", ctx, "ab").Return(cached, nil).Once()
+			mockPg.On("SaveClick", mock.Anything, mock.MatchedBy(func(click domain.Stats) bool {
+				served = click.Variant
+				return true
+			})).Return(nil).Once()
+
+			redirect, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "ab", Variant: tt.sticky})
+
+			assert.NoError(t, err)
+			assert.NoError(t, uc.Close())
+			if tt.want != "" {
+				assert.Equal(t, tt.want, redirect.Variant)
+			}
+			assert.Contains(t, urls, redirect.Variant)
+			assert.Equal(t, urls[redirect.Variant], redirect.URL)
+			assert.True(t, redirect.StickyVariant)
+			assert.True(t, redirect.Varies)
+			assert.Equal(t, redirect.Variant, served)
+		})
+	}
+}
+
+func TestChooseVariant(t *testing.T) {
+	link := domain.Shortener{Variants: []domain.Variant{
+		{Name: "a", URL: "https://a.example", Weight: 70},
+		{Name: "b", URL: "https://b.example", Weight: 30},
+	}}
+
+	for roll, want := range map[int]string{0: "a", 69: "a", 70: "b", 99: "b"} {
+		v, ok := link.ChooseVariant("", func(n int) int {
+			assert.Equal(t, 100, n)
+			return roll
+		})
+		assert.True(t, ok)
+		assert.Equal(t, want, v.Name, roll)
+	}
+
+	_, ok := domain.Shortener{}.ChooseVariant("a", func(int) int { return 0 })
+	assert.False(t, ok)
+}
+
+func TestShortenerUsecase_PasswordProtected(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := `{"url":"https://docs.example","status":302,"password_hash":"` + string(hash) + `"}`
+	attemptsKey := "unlock:docs:10.0.0.1"
+	failuresKey := "unlock:docs"
+	window := 15 * time.Minute
+
+	t.Run("shorten stores only hash", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockPg.On("Save", ctx, mock.MatchedBy(func(s domain.Shortener) bool {
+			return s.Password == "" && bcrypt.CompareHashAndPassword([]byte(s.PasswordHash), []byte("s3cret")) == nil
+		})).Return(nil).Once()
+		mockRedis.On("SetWithExpiration", ctx, "docs", mock.Anything, TTL).Return(nil).Once()
+
+		link := domain.Shortener{ShortCode: "docs", LongURL: "https://docs.example", Password: "s3cret"}
+		_, err := uc.Shorten(ctx, link, domain.Idempotency{})
+
+		assert.NoError(t, err)
+		assert.NoError(t, uc.Close())
+		mockPg.AssertExpectations(t)
+	})
+
+	tests := []struct {
+		name     string
+		password string
+		attempts int64
+		// failures - неверных паролей к ссылке со всех IP; "" - счетчика нет
+		failures string
+		wantErr  error
+	}{
+		{name: "form not submitted", wantErr: domain.ErrPasswordRequired},
+		{name: "wrong password", password: "guess", attempts: 1, failures: "3", wantErr: domain.ErrWrongPassword},
+		{name: "too many attempts", password: "s3cret", attempts: 6, wantErr: domain.ErrTooManyAttempts},
+		// Перебор с подменой IP: у каждого адреса первая попытка, но
+		// ссылка уже получила предел неверных паролей.
+		{name: "too many failures for link", password: "s3cret", attempts: 1, failures: "100", wantErr: domain.ErrTooManyAttempts},
+		{name: "unlocked", password: "s3cret", attempts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPg := new(MockPostgres)
+			mockRedis := new(MockRedis)
+			uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+			mockRedis.On("Get", ctx, "docs").Return(cached, nil).Once()
+			if tt.attempts > 0 {
+				mockRedis.On("Incr", ctx, attemptsKey, window).Return(tt.attempts, nil).Once()
+			}
+			if tt.attempts > 0 && tt.attempts <= 5 {
+				if tt.failures == "" {
+					mockRedis.On("Get", ctx, failuresKey).Return("", errors.New("redis: nil")).Once()
+				} else {
+					mockRedis.On("Get", ctx, failuresKey).Return(tt.failures, nil).Once()
+				}
+			}
+			if errors.Is(tt.wantErr, domain.ErrWrongPassword) {
+				mockRedis.On("Incr", ctx, failuresKey, window).Return(int64(4), nil).Once()
+			}
+			if tt.wantErr == nil {
+				mockRedis.On("Del", ctx, attemptsKey).Return(nil).Once()
+				mockPg.On("SaveClick", mock.Anything, mock.AnythingOfType("domain.Stats")).Return(nil).Once()
+			}
+
+			redirect, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "docs", IP: "10.0.0.1", Password: tt.password})
+
+			// Close дожидается воркера аналитики: клик до разблокировки не пишется.
+			assert.NoError(t, uc.Close())
+			mockRedis.AssertExpectations(t)
+			mockPg.AssertExpectations(t)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockPg.AssertNotCalled(t, "SaveClick", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "https://docs.example", redirect.URL)
+			assert.True(t, redirect.Varies)
+		})
+	}
+}
+
+func TestShortenerUsecase_Preview(t *testing.T) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	tests := map[string]struct {
+		link    domain.Shortener
+		wantErr error
+	}{
+		"active":   {link: domain.Shortener{ShortCode: "docs", LongURL: "https://docs.example", Title: "Docs"}},
+		"disabled": {link: domain.Shortener{ShortCode: "docs", Disabled: true}, wantErr: domain.ErrDisabled},
+		"expired":  {link: domain.Shortener{ShortCode: "docs", ExpiresAt: &past}, wantErr: domain.ErrExpired},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockPg := new(MockPostgres)
+			mockRedis := new(MockRedis)
+			uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+			mockPg.On("Get", ctx, "docs").Return(tt.link, nil).Once()
+
+			link, err := uc.Preview(ctx, "docs")
+
+			assert.NoError(t, uc.Close())
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Equal(t, tt.link, link)
+			}
+			mockPg.AssertNotCalled(t, "SaveClick", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestShortenerUsecase_MaxClicks(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		cached  string
+		consume error
+		wantErr error
+	}{
+		{name: "unlimited link", cached: `{"url":"https://invite.example","status":302}`},
+		{name: "click left", cached: `{"url":"https://invite.example","status":301,"max_clicks":1}`},
+		{
+			name:    "limit reached",
+			cached:  `{"url":"https://invite.example","status":302,"max_clicks":1}`,
+			consume: domain.ErrClicksExhausted,
+			wantErr: domain.ErrClicksExhausted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPg := new(MockPostgres)
+			mockRedis := new(MockRedis)
+			uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+			mockRedis.On("Get", ctx, "invite").Return(tt.cached, nil).Once()
+			if strings.Contains(tt.cached, "max_clicks") {
+				mockPg.On("ConsumeClick", ctx, "invite").Return(tt.consume).Once()
+			}
+			if tt.wantErr == nil {
+				mockPg.On("SaveClick", mock.Anything, mock.AnythingOfType("domain.Stats")).Return(nil).Once()
+			}
+
+			redirect, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "invite"})
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			// Постоянный редирект ссылки с лимитом нельзя кэшировать.
+			assert.Equal(t, tt.wantErr == nil && strings.Contains(tt.cached, "max_clicks"), redirect.Varies)
+			assert.NoError(t, uc.Close())
+			mockPg.AssertExpectations(t)
+		})
+	}
+
+	t.Run("bots do not consume clicks nor get the url", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		cached := `{"url":"https://invite.example","status":302,"max_clicks":1}`
+		mockRedis.On("Get", ctx, "invite").Return(cached, nil).Twice()
+		mockPg.On("Get", ctx, "invite").Return(domain.Shortener{ShortCode: "invite", MaxClicks: 1}, nil).Twice()
+		mockPg.On("SaveClick", mock.Anything, mock.MatchedBy(func(click domain.Stats) bool { return click.Bot })).Return(nil).Twice()
+
+		for _, v := range []domain.Visit{
+			{ShortCode: "invite", UserAgent: "TelegramBot (like TwitterBot)", Method: http.MethodGet},
+			{ShortCode: "invite", UserAgent: "Mozilla/5.0 Firefox/121.0", Method: http.MethodHead},
+		} {
+			redirect, err := uc.GetOriginal(ctx, v)
+			assert.NoError(t, err)
+			assert.True(t, redirect.Withheld)
+			assert.Empty(t, redirect.URL)
+		}
+
+		assert.NoError(t, uc.Close())
+		mockPg.AssertExpectations(t)
+		mockPg.AssertNotCalled(t, "ConsumeClick", mock.Anything, mock.Anything)
+	})
+
+	t.Run("bots do not pass exhausted link", func(t *testing.T) {
+		mockPg := new(MockPostgres)
+		mockRedis := new(MockRedis)
+		uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+		mockRedis.On("Get", ctx, "invite").Return(`{"url":"https://invite.example","status":302,"max_clicks":1}`, nil).Once()
+		mockPg.On("Get", ctx, "invite").Return(domain.Shortener{ShortCode: "invite", MaxClicks: 1, Clicks: 1}, nil).Once()
+
+		_, err := uc.GetOriginal(ctx, domain.Visit{ShortCode: "invite", UserAgent: "Slackbot-LinkExpanding 1.0"})
+
+		assert.ErrorIs(t, err, domain.ErrClicksExhausted)
+		assert.NoError(t, uc.Close())
+		mockPg.AssertNotCalled(t, "ConsumeClick", mock.Anything, mock.Anything)
+	})
+}
+
+func TestShortenerUsecase_ActiveFrom(t *testing.T) {
+	ctx := context.Background()
+	activeFrom := time.Now().Add(time.Hour)
+	link := domain.Shortener{ShortCode: "press", LongURL: "https://news.example/release", ActiveFrom: &activeFrom}
+
+	mockPg := new(MockPostgres)
+	mockRedis := new(MockRedis)
+	uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log.New(), TTL, cfg, domain.AliasPolicy{})
+
+	// Ссылка с эмбарго не попадает в Redis ни при создании, ни при переходе:
+	// запись кэша отдавалась бы без проверки active_from.
+	mockPg.On("Save", ctx, link).Return(nil).Once()
+	mockRedis.On("Get", ctx, "press").Return("", errors.New("redis: nil")).Once()
+	mockPg.On("Get", ctx, "press").Return(link, nil).Once()
+
+	code, err := uc.Shorten(ctx, link, domain.Idempotency{})
+	assert.NoError(t, err)
+	assert.Equal(t, "press", code)
+
+	_, err = uc.GetOriginal(ctx, domain.Visit{ShortCode: "press"})
+	assert.ErrorIs(t, err, domain.ErrNotYetActive)
+
+	assert.NoError(t, uc.Close())
+	mockPg.AssertExpectations(t)
+	mockRedis.AssertNotCalled(t, "SetWithExpiration", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockPg.AssertNotCalled(t, "SaveClick", mock.Anything, mock.Anything)
+}
+
+func TestShortenerUsecase_Fallback(t *testing.T) {
+	ctx := context.Background()
+	owners := map[string]string{"marketing": "https://marketing.example"}
+
+	tests := []struct {
+		name   string
+		cfg    usecase.Config
+		owner  string
+		ownErr error
+		want   string
+		wantOK bool
+	}{
+		{name: "no fallback"},
+		{name: "global fallback", cfg: usecase.Config{FallbackURL: "https://example.com"}, want: "https://example.com", wantOK: true},
+		{
+			name:   "owner fallback",
+			cfg:    usecase.Config{FallbackURL: "https://example.com", OwnerFallbackURLs: owners},
+			owner:  "Marketing",
+			want:   "https://marketing.example",
+			wantOK: true,
+		},
+		{
+			name:   "owner without fallback",
+			cfg:    usecase.Config{FallbackURL: "https://example.com", OwnerFallbackURLs: owners},
+			owner:  "sales",
+			want:   "https://example.com",
+			wantOK: true,
+		},
+		{
+			name:   "unknown code",
+			cfg:    usecase.Config{OwnerFallbackURLs: owners},
+			ownErr: domain.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPg := new(MockPostgres)
+			uc := usecase.New(mockPg, new(MockRedis), newGenerator(t), nil, log.New(), TTL, tt.cfg, domain.AliasPolicy{})
+			if len(tt.cfg.OwnerFallbackURLs) > 0 {
+				mockPg.On("Owner", ctx, "gone").Return(tt.owner, tt.ownErr).Once()
+			}
+
+			got, ok := uc.Fallback(ctx, "gone")
+
+			assert.NoError(t, uc.Close())
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOK, ok)
+			mockPg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestShortenerUsecase_ManageLinks(t *testing.T) {
+	mockPg := new(MockPostgres)
+	mockRedis := new(MockRedis)
+	log := log.New()
+	ctx := context.Background()
+
+	uc := usecase.New(mockPg, mockRedis, newGenerator(t), nil, log, TTL, cfg, domain.AliasPolicy{})
+	shortCode := "abcdef"
+
+	t.Run("update invalidates cache", func(t *testing.T) {
+		ownerCtx := domain.WithOwner(ctx, "marketing")
+		newURL := "https://example.org"
+		upd := domain.LinkUpdate{LongURL: &newURL}
+
+		mockPg.On("Get", ownerCtx, shortCode).Return(domain.Shortener{ShortCode: shortCode, OwnerID: "marketing"}, nil).Once()
+		mockPg.On("Update", ownerCtx, shortCode, upd).Return(domain.Shortener{ShortCode: shortCode, LongURL: newURL}, nil).Once()
+		mockRedis.On("Del", ownerCtx, shortCode).Return(nil).Once()
+
+		link, err := uc.UpdateLink(ownerCtx, shortCode, upd)
+
+		assert.NoError(t, err)
+		assert.Equal(t, newURL, link.LongURL)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("update checks merged activation window", func(t *testing.T) {
+		ownerCtx := domain.WithOwner(ctx, "marketing")
+		expiresAt := time.Now().Add(time.Hour)
+		activeFrom := expiresAt.Add(time.Hour)
+		link := domain.Shortener{ShortCode: shortCode, LongURL: "https://example.com", OwnerID: "marketing", ExpiresAt: &expiresAt}
+		upd := domain.LinkUpdate{ActiveFrom: &domain.TimeUpdate{Time: &activeFrom}}
+		mockPg.On("Get", ownerCtx, shortCode).Return(link, nil).Once()
+
+		_, err := uc.UpdateLink(ownerCtx, shortCode, upd)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidActivation)
+		mockPg.AssertNotCalled(t, "Update", ownerCtx, shortCode, upd)
+
+		// Снятие срока жизни делает тот же active_from допустимым.
+		upd.ExpiresAt = &domain.TimeUpdate{}
+		mockPg.On("Get", ownerCtx, shortCode).Return(link, nil).Once()
+		mockPg.On("Update", ownerCtx, shortCode, upd).Return(domain.Shortener{ShortCode: shortCode, ActiveFrom: &activeFrom}, nil).Once()
+		mockRedis.On("Del", ownerCtx, shortCode).Return(nil).Once()
+
+		_, err = uc.UpdateLink(ownerCtx, shortCode, upd)
+
+		assert.NoError(t, err)
+		mockPg.AssertExpectations(t)
+	})
+
+	t.Run("update rejects past expiration", func(t *testing.T) {
+		ownerCtx := domain.WithOwner(ctx, "marketing")
+		past := time.Now().Add(-time.Hour)
+		upd := domain.LinkUpdate{ExpiresAt: &domain.TimeUpdate{Time: &past}}
+		mockPg.On("Get", ownerCtx, shortCode).Return(domain.Shortener{ShortCode: shortCode, LongURL: "https://example.com", OwnerID: "marketing"}, nil).Once()
+
+		_, err := uc.UpdateLink(ownerCtx, shortCode, upd)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidExpiration)
+		mockPg.AssertExpectations(t)
+	})
+
+	t.Run("delete missing link", func(t *testing.T) {
+		mockPg.On("Get", ctx, shortCode).Return(domain.Shortener{}, domain.ErrNotFound).Once()
+
+		err := uc.DeleteLink(ctx, shortCode)
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertNumberOfCalls(t, "Del", 2) // only the ones from the updates above
+	})
+
+	t.Run("foreign link is forbidden", func(t *testing.T) {
+		ownerCtx := domain.WithOwner(ctx, "sales")
+		mockPg.On("Get", ownerCtx, shortCode).Return(domain.Shortener{ShortCode: shortCode, OwnerID: "marketing"}, nil).Once()
+
+		err := uc.DeleteLink(ownerCtx, shortCode)
+
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		mockPg.AssertExpectations(t)
+		mockPg.AssertNotCalled(t, "Delete", ownerCtx, shortCode)
+	})
+
+	t.Run("anonymous update of ownerless link is forbidden", func(t *testing.T) {
+		phishing := "https://phishing.example"
+		upd := domain.LinkUpdate{LongURL: &phishing}
+		mockPg.On("Get", ctx, shortCode).Return(domain.Shortener{ShortCode: shortCode}, nil).Once()
+
+		_, err := uc.UpdateLink(ctx, shortCode, upd)
+
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		mockPg.AssertExpectations(t)
+		mockPg.AssertNotCalled(t, "Update", ctx, shortCode, upd)
+	})
+
+	t.Run("anonymous delete of ownerless link is forbidden", func(t *testing.T) {
+		mockPg.On("Get", ctx, shortCode).Return(domain.Shortener{ShortCode: shortCode}, nil).Once()
+
+		err := uc.DeleteLink(ctx, shortCode)
+
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		mockPg.AssertExpectations(t)
+		mockPg.AssertNotCalled(t, "Delete", ctx, shortCode)
+	})
+
+	t.Run("admin deletes ownerless link", func(t *testing.T) {
+		adminCtx := domain.WithAdmin(ctx)
+		mockPg.On("Get", adminCtx, shortCode).Return(domain.Shortener{ShortCode: shortCode}, nil).Once()
+		mockPg.On("Delete", adminCtx, shortCode).Return(nil).Once()
+		mockRedis.On("Del", adminCtx, shortCode).Return(nil).Once()
+
+		err := uc.DeleteLink(adminCtx, shortCode)
+
+		assert.NoError(t, err)
+		mockPg.AssertExpectations(t)
+		mockRedis.AssertExpectations(t)
+	})
+
+	t.Run("stats of own link", func(t *testing.T) {
+		ownerCtx := domain.WithOwner(ctx, "marketing")
+		mockPg.On("Get", ownerCtx, shortCode).Return(domain.Shortener{ShortCode: shortCode, OwnerID: "marketing"}, nil).Once()
+		q, err := domain.NewStatsQuery("", "", "", "", time.Now())
+		assert.NoError(t, err)
+		mockPg.On("GetDetailedStats", ownerCtx, shortCode, q).Return(domain.Stats{TotalClicks: 3}, nil).Once()
+
+		stats, err := uc.GetStats(ownerCtx, shortCode, q)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, stats.TotalClicks)
+		mockPg.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/adexcell/shortener/internal/domain"
+)
+
+const (
+	// visitorSaltTTL - сколько соль суток живет в Redis. Запас на сутки
+	// покрывает клики, дошедшие до воркера после полуночи.
+	visitorSaltTTL = 48 * time.Hour
+	// visitorsDayTTL - сколько хранится HyperLogLog посетителей за сутки.
+	visitorsDayTTL = 48 * time.Hour
+	// visitorsTotalTTL - HyperLogLog посетителей за все время продлевается
+	// каждым кликом и исчезает у ссылок, по которым долго не переходят.
+	visitorsTotalTTL = 90 * 24 * time.Hour
+)
+
+// visitorSalt - соль отпечатков посетителей на текущие сутки.
+type visitorSalt struct {
+	mu   sync.Mutex
+	day  string
+	salt string
+}
+
+// visitorDay - сутки (UTC), к которым относятся соль и счетчики посетителей.
+func visitorDay(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// visitorID возвращает отпечаток посетителя: хэш IP и User-Agent с солью
+// суток. Соль общая для всех экземпляров сервиса и меняется раз в сутки,
+// поэтому по отпечатку нельзя восстановить IP, а связать визиты одного
+// посетителя можно только в пределах суток.
+func (u *ShortenerUsecase) visitorID(ctx context.Context, ip, userAgent string, now time.Time) string {
+	salt := u.salt(ctx, visitorDay(now))
+	sum := sha256.Sum256([]byte(salt + "|" + ip + "|" + userAgent))
+	return hex.EncodeToString(sum[:16])
+}
+
+// salt возвращает соль суток day. Первый экземпляр сервиса записывает ее в
+// Redis, остальные читают. Без Redis соль генерируется локально: отпечатки
+// остаются анонимными, но разные экземпляры посчитают посетителя дважды.
+func (u *ShortenerUsecase) salt(ctx context.Context, day string) string {
+	u.visitorSalt.mu.Lock()
+	defer u.visitorSalt.mu.Unlock()
+	if u.visitorSalt.day == day {
+		return u.visitorSalt.salt
+	}
+
+	salt := rand.Text()
+	key := "visitor_salt:" + day
+	if _, err := u.redis.SetNX(ctx, key, salt, visitorSaltTTL); err != nil {
+		u.log.Error().Err(err).Msg("failed to store visitor salt in redis, using local salt")
+	} else if stored, err := u.redis.Get(ctx, key); err != nil {
+		u.log.Error().Err(err).Msg("failed to get visitor salt from redis, using local salt")
+	} else {
+		salt = stored
+	}
+
+	u.visitorSalt.day = day
+	u.visitorSalt.salt = salt
+
+	return salt
+}
+
+func visitorsDayKey(shortCode, day string) string {
+	return "uv:" + shortCode + ":" + day
+}
+
+func visitorsTotalKey(shortCode string) string {
+	return "uv:" + shortCode
+}
+
+// countVisitor добавляет посетителя клика в HyperLogLog ссылки за сутки и
+// за все время.
+func (u *ShortenerUsecase) countVisitor(ctx context.Context, click domain.Stats) {
+	if click.VisitorID == "" {
+		return
+	}
+
+	day := visitorDay(time.Now())
+	if err := u.redis.PFAdd(ctx, visitorsDayKey(click.ShortCode, day), click.VisitorID, visitorsDayTTL); err != nil {
+		u.log.Error().Err(err).Str("code", click.ShortCode).Msg("failed to count visitor in redis")
+		return
+	}
+	if err := u.redis.PFAdd(ctx, visitorsTotalKey(click.ShortCode), click.VisitorID, visitorsTotalTTL); err != nil {
+		u.log.Error().Err(err).Str("code", click.ShortCode).Msg("failed to count visitor in redis")
+	}
+}
+
+// liveVisitors возвращает оценку уникальных посетителей по HyperLogLog в
+// Redis. Недоступный Redis не мешает отдать статистику из Postgres.
+func (u *ShortenerUsecase) liveVisitors(ctx context.Context, shortCode string) *domain.LiveVisitors {
+	today, err := u.redis.PFCount(ctx, visitorsDayKey(shortCode, visitorDay(time.Now())))
+	if err != nil {
+		u.log.Error().Err(err).Str("code", shortCode).Msg("failed to count visitors in redis")
+		return nil
+	}
+	total, err := u.redis.PFCount(ctx, visitorsTotalKey(shortCode))
+	if err != nil {
+		u.log.Error().Err(err).Str("code", shortCode).Msg("failed to count visitors in redis")
+		return nil
+	}
+
+	return &domain.LiveVisitors{Today: int(today), DailyTotal: int(total)}
+}
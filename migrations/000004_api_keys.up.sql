@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    owner_id VARCHAR(64) NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    key_hash CHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ
+);
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS owner_id VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS urls_owner_id_idx ON urls (owner_id);
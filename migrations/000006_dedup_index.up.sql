@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS urls_owner_long_url_idx ON urls (owner_id, md5(long_url))
+    WHERE deleted_at IS NULL;
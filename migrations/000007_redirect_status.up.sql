@@ -0,0 +1,3 @@
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS redirect_status SMALLINT NOT NULL DEFAULT 302
+        CHECK (redirect_status IN (301, 302, 307, 308));
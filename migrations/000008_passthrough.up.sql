@@ -0,0 +1,4 @@
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS query_mode TEXT NOT NULL DEFAULT 'none'
+        CHECK (query_mode IN ('none', 'keep', 'override', 'append')),
+    ADD COLUMN IF NOT EXISTS path_passthrough BOOLEAN NOT NULL DEFAULT FALSE;
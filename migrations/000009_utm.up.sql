@@ -0,0 +1,7 @@
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS utm JSONB;
+
+ALTER TABLE analytics
+    ADD COLUMN IF NOT EXISTS utm_campaign TEXT;
+
+CREATE INDEX IF NOT EXISTS analytics_short_code_campaign_idx ON analytics (short_code, utm_campaign);
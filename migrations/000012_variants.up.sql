@@ -0,0 +1,6 @@
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS variants JSONB,
+    ADD COLUMN IF NOT EXISTS sticky_variants BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE analytics
+    ADD COLUMN IF NOT EXISTS variant TEXT;
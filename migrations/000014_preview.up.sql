@@ -0,0 +1,4 @@
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS title TEXT,
+    ADD COLUMN IF NOT EXISTS interstitial_seconds SMALLINT NOT NULL DEFAULT 0
+        CHECK (interstitial_seconds BETWEEN 0 AND 30);
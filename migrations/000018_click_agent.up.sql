@@ -0,0 +1,8 @@
+-- Разобранный User-Agent клика. Старые клики остаются с NULL и попадают
+-- в разбивки как other.
+ALTER TABLE analytics
+    ADD COLUMN IF NOT EXISTS browser TEXT,
+    ADD COLUMN IF NOT EXISTS browser_version TEXT,
+    ADD COLUMN IF NOT EXISTS os TEXT,
+    ADD COLUMN IF NOT EXISTS device TEXT,
+    ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;
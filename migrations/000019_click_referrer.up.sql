@@ -0,0 +1,2 @@
+ALTER TABLE analytics
+    ADD COLUMN IF NOT EXISTS referrer TEXT;
@@ -0,0 +1,3 @@
+-- Анонимный отпечаток посетителя: хэш IP и User-Agent с солью суток.
+ALTER TABLE analytics
+    ADD COLUMN IF NOT EXISTS visitor_id TEXT;
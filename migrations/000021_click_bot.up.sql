@@ -0,0 +1,16 @@
+-- Имя бота клика для разбивки by_bot. Старые клики ботов остаются с NULL
+-- и попадают в разбивку как other.
+ALTER TABLE analytics
+    ADD COLUMN IF NOT EXISTS bot TEXT;
+
+-- Сервисы превью ссылок, чей User-Agent не содержит "bot", раньше не
+-- распознавались.
+UPDATE analytics
+SET is_bot = TRUE
+WHERE NOT is_bot
+    AND (user_agent ILIKE '%facebookexternalhit%'
+        OR user_agent ILIKE '%facebookcatalog%'
+        OR user_agent ILIKE '%whatsapp%'
+        OR user_agent ILIKE '%skypeuripreview%'
+        OR user_agent ILIKE '%vkshare%'
+        OR user_agent ILIKE '%embedly%');
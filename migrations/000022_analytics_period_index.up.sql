@@ -0,0 +1,2 @@
+-- Статистика всегда читается по коду за период [from, to).
+CREATE INDEX IF NOT EXISTS analytics_short_code_clicked_at_idx ON analytics (short_code, clicked_at);
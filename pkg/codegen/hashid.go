@@ -0,0 +1,102 @@
+package codegen
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCode = errors.New("code was not produced by this encoder")
+
+// Hashid - солёное биективное кодирование чисел в строки в духе hashids.
+// Соседние числа дают непохожие коды, а каждое число кодируется ровно одним
+// кодом и однозначно восстанавливается из него.
+type Hashid struct {
+	alphabet string
+	salt     string
+}
+
+func NewHashid(alphabet, salt string) (*Hashid, error) {
+	if alphabet == "" {
+		alphabet = Base62
+	}
+	if err := ValidateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+
+	return &Hashid{
+		alphabet: shuffle(alphabet, salt),
+		salt:     salt,
+	}, nil
+}
+
+// Encode кодирует id. Первый символ ("лотерея") зависит от id и задает
+// перестановку алфавита для остальных символов.
+func (h *Hashid) Encode(id uint64) string {
+	n := uint64(len(h.alphabet))
+	lottery := h.alphabet[id%n]
+	alphabet := h.alphabetFor(lottery)
+
+	var digits []byte
+	for {
+		digits = append(digits, alphabet[id%n])
+		id /= n
+		if id == 0 {
+			break
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(digits) + 1)
+	sb.WriteByte(lottery)
+	for i := len(digits) - 1; i >= 0; i-- {
+		sb.WriteByte(digits[i])
+	}
+	return sb.String()
+}
+
+// Decode восстанавливает id из кода, полученного Encode.
+func (h *Hashid) Decode(code string) (uint64, error) {
+	if len(code) < 2 {
+		return 0, ErrInvalidCode
+	}
+
+	alphabet := h.alphabetFor(code[0])
+	n := uint64(len(alphabet))
+
+	var id uint64
+	for i := 1; i < len(code); i++ {
+		d := strings.IndexByte(alphabet, code[i])
+		if d < 0 {
+			return 0, ErrInvalidCode
+		}
+		id = id*n + uint64(d)
+	}
+
+	if h.Encode(id) != code {
+		return 0, ErrInvalidCode
+	}
+	return id, nil
+}
+
+func (h *Hashid) alphabetFor(lottery byte) string {
+	buf := string(lottery) + h.salt + h.alphabet
+	return shuffle(h.alphabet, buf[:len(h.alphabet)])
+}
+
+// shuffle детерминированно перемешивает алфавит в зависимости от соли.
+func shuffle(alphabet, salt string) string {
+	if salt == "" {
+		return alphabet
+	}
+
+	res := []byte(alphabet)
+	for i, v, p := len(res)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		c := int(salt[v])
+		p += c
+		j := (c + v + p) % i
+		res[i], res[j] = res[j], res[i]
+		v++
+	}
+	return string(res)
+}
@@ -0,0 +1,51 @@
+package codegen_test
+
+import (
+	"testing"
+
+	"github.com/adexcell/shortener/pkg/codegen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashid(t *testing.T) {
+	h, err := codegen.NewHashid("", "pepper")
+	require.NoError(t, err)
+
+	t.Run("round trip without collisions", func(t *testing.T) {
+		seen := make(map[string]uint64)
+		for id := uint64(0); id < 20000; id++ {
+			code := h.Encode(id)
+
+			prev, dup := seen[code]
+			require.False(t, dup, "ids %d and %d share code %q", prev, id, code)
+			seen[code] = id
+
+			got, err := h.Decode(code)
+			require.NoError(t, err)
+			require.Equal(t, id, got)
+		}
+	})
+
+	t.Run("codes stay short", func(t *testing.T) {
+		assert.Len(t, h.Encode(61), 2)
+		assert.Len(t, h.Encode(1_000_000), 5)
+		assert.LessOrEqual(t, len(h.Encode(1<<52)), 10)
+	})
+
+	t.Run("salt changes codes", func(t *testing.T) {
+		other, err := codegen.NewHashid("", "salt")
+		require.NoError(t, err)
+
+		assert.NotEqual(t, h.Encode(42), other.Encode(42))
+	})
+
+	t.Run("neighbours look different", func(t *testing.T) {
+		assert.NotEqual(t, h.Encode(1000)[:2], h.Encode(1001)[:2])
+	})
+
+	t.Run("foreign code", func(t *testing.T) {
+		_, err := h.Decode("a")
+		assert.ErrorIs(t, err, codegen.ErrInvalidCode)
+	})
+}
@@ -0,0 +1,74 @@
+// Package codegen содержит генераторы коротких кодов.
+package codegen
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Base62 - алфавит по умолчанию: без "-" и "_", которые пользователи путают
+// и теряют при копировании.
+const Base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// unreserved - символы, которые можно использовать в пути URL без экранирования.
+const unreserved = Base62 + "-._~"
+
+var ErrInvalidAlphabet = errors.New("alphabet must contain at least 2 unique URL-safe characters")
+
+// Random генерирует коды из случайных символов алфавита.
+type Random struct {
+	alphabet string
+	max      *big.Int
+}
+
+func NewRandom(alphabet string) (*Random, error) {
+	if alphabet == "" {
+		alphabet = Base62
+	}
+	if err := ValidateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+
+	return &Random{
+		alphabet: alphabet,
+		max:      big.NewInt(int64(len(alphabet))),
+	}, nil
+}
+
+// Generate возвращает код заданной длины. Символы выбираются равновероятно
+// с помощью crypto/rand.
+func (r *Random) Generate(_ context.Context, length int) (string, error) {
+	var sb strings.Builder
+	sb.Grow(length)
+
+	for range length {
+		n, err := rand.Int(rand.Reader, r.max)
+		if err != nil {
+			return "", fmt.Errorf("rand.Int: %w", err)
+		}
+		sb.WriteByte(r.alphabet[n.Int64()])
+	}
+
+	return sb.String(), nil
+}
+
+// ValidateAlphabet проверяет, что алфавит состоит из уникальных URL-безопасных символов.
+func ValidateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return ErrInvalidAlphabet
+	}
+
+	seen := make(map[rune]bool, len(alphabet))
+	for _, c := range alphabet {
+		if seen[c] || !strings.ContainsRune(unreserved, c) {
+			return ErrInvalidAlphabet
+		}
+		seen[c] = true
+	}
+
+	return nil
+}
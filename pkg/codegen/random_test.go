@@ -0,0 +1,45 @@
+package codegen_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/adexcell/shortener/pkg/codegen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandom_Generate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("default alphabet", func(t *testing.T) {
+		g, err := codegen.NewRandom("")
+		require.NoError(t, err)
+
+		code, err := g.Generate(ctx, 8)
+
+		assert.NoError(t, err)
+		assert.Len(t, code, 8)
+		for _, c := range code {
+			assert.True(t, strings.ContainsRune(codegen.Base62, c))
+		}
+	})
+
+	t.Run("custom alphabet", func(t *testing.T) {
+		g, err := codegen.NewRandom("ab")
+		require.NoError(t, err)
+
+		code, err := g.Generate(ctx, 32)
+
+		assert.NoError(t, err)
+		assert.Empty(t, strings.Trim(code, "ab"))
+	})
+
+	t.Run("invalid alphabet", func(t *testing.T) {
+		for _, alphabet := range []string{"a", "aab", "ab/", "ab c"} {
+			_, err := codegen.NewRandom(alphabet)
+			assert.ErrorIs(t, err, codegen.ErrInvalidAlphabet, alphabet)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+// Package geoip определяет страну по IP по локальной базе в формате MaxMind (.mmdb).
+package geoip
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type Config struct {
+	// Path - путь к файлу .mmdb (GeoLite2-Country, GeoIP2-Country или City).
+	// Пустое значение выключает определение страны.
+	Path string `mapstructure:"path"`
+}
+
+type Reader struct {
+	db *maxminddb.Reader
+}
+
+// record - часть записи базы MaxMind, нужная для определения страны.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// Open открывает базу. Файл отображается в память и не перечитывается.
+func Open(path string) (*Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{db: db}, nil
+}
+
+// Country возвращает ISO 3166-1 alpha-2 код страны или пустую строку, если
+// IP невалиден или отсутствует в базе.
+func (r *Reader) Country(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+
+	var rec record
+	if err := r.db.Lookup(addr, &rec); err != nil {
+		return ""
+	}
+
+	return rec.Country.ISOCode
+}
+
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
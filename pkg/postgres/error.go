@@ -6,9 +6,11 @@ import (
 	"github.com/lib/pq"
 )
 
-func PostgresErr(err error) error {
-	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
-		return errors.New("this alias is already taken")
-	}
-	return err
+// uniqueViolation - код ошибки Postgres при нарушении UNIQUE.
+const uniqueViolation = "23505"
+
+// IsUniqueViolation сообщает, что запись не вставлена из-за нарушения уникальности.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pq.Error
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
 }
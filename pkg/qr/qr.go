@@ -0,0 +1,181 @@
+// Package qr рисует QR-коды в PNG и SVG. Матрица кода строится библиотекой
+// go-qrcode, а отрисовка своя: библиотека не умеет задавать поле и SVG.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Форматы изображения.
+const (
+	PNG = "png"
+	SVG = "svg"
+)
+
+// Ограничения параметров.
+const (
+	MinSize   = 64
+	MaxSize   = 2048
+	MaxMargin = 16
+)
+
+// Options - параметры изображения.
+type Options struct {
+	// Format - PNG или SVG
+	Format string
+	// Size - сторона изображения в пикселях
+	Size int
+	// Margin - ширина светлого поля вокруг кода в модулях
+	Margin int
+	// Level - уровень коррекции ошибок: L, M, Q или H
+	Level string
+	// Foreground и Background - цвета модулей и фона
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// DefaultOptions - черный код 256x256 со стандартным полем в 4 модуля.
+func DefaultOptions() Options {
+	return Options{
+		Format:     PNG,
+		Size:       256,
+		Margin:     4,
+		Level:      "M",
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+var (
+	ErrInvalidFormat = errors.New("format must be png or svg")
+	ErrInvalidLevel  = errors.New("level must be one of L, M, Q, H")
+	ErrInvalidSize   = fmt.Errorf("size must be between %d and %d", MinSize, MaxSize)
+	ErrInvalidMargin = fmt.Errorf("margin must be between 0 and %d", MaxMargin)
+	ErrInvalidColor  = errors.New("color must be a hex RRGGBB value")
+	ErrSizeTooSmall  = errors.New("size is too small for the code, increase size or lower level")
+)
+
+// Validate проверяет параметры изображения.
+func (o Options) Validate() error {
+	if o.Format != PNG && o.Format != SVG {
+		return ErrInvalidFormat
+	}
+	if _, ok := levels[o.Level]; !ok {
+		return ErrInvalidLevel
+	}
+	if o.Size < MinSize || o.Size > MaxSize {
+		return ErrInvalidSize
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return ErrInvalidMargin
+	}
+
+	return nil
+}
+
+// ParseColor разбирает цвет в формате RRGGBB, допускается ведущий "#".
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, ErrInvalidColor
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, ErrInvalidColor
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// Encode рисует QR-код с содержимым content.
+func Encode(content string, o Options) ([]byte, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	code, err := qrcode.New(content, levels[o.Level])
+	if err != nil {
+		return nil, fmt.Errorf("qrcode.New: %w", err)
+	}
+	code.DisableBorder = true
+	bitmap := code.Bitmap()
+
+	if o.Format == SVG {
+		return encodeSVG(bitmap, o), nil
+	}
+	return encodePNG(bitmap, o)
+}
+
+// encodePNG рисует модули целым числом пикселей, чтобы сканеры не
+// спотыкались о размытые границы; остаток стороны уходит в поле.
+func encodePNG(bitmap [][]bool, o Options) ([]byte, error) {
+	modules := len(bitmap) + 2*o.Margin
+	scale := o.Size / modules
+	if scale == 0 {
+		return nil, ErrSizeTooSmall
+	}
+	offset := (o.Size-scale*modules)/2 + o.Margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, o.Size, o.Size), color.Palette{o.Background, o.Foreground})
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := range scale {
+				for dx := range scale {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("png.Encode: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeSVG рисует темные модули одним path в координатах модулей.
+func encodeSVG(bitmap [][]bool, o Options) []byte {
+	modules := len(bitmap) + 2*o.Margin
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		o.Size, o.Size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, hex(o.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hex(o.Foreground))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+o.Margin, y+o.Margin)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes()
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
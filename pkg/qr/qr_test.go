@@ -0,0 +1,121 @@
+package qr_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/adexcell/shortener/pkg/qr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const content = "https://sho.rt/s/abc123?_src=qr"
+
+func TestEncode_PNG(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Size = 300
+	opts.Foreground = color.RGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}
+
+	img := decodePNG(t, opts)
+
+	assert.Equal(t, image.Rect(0, 0, 300, 300), img.Bounds())
+	assert.Equal(t, opts.Background, rgba(img.At(0, 0)))
+
+	// Диагональ из угла сначала идет по полю, затем упирается в искатель.
+	withMargin := firstDark(img, opts.Foreground)
+	assert.Positive(t, withMargin)
+
+	opts.Margin = 0
+	assert.Less(t, firstDark(decodePNG(t, opts), opts.Foreground), withMargin)
+}
+
+func TestEncode_SVG(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Format = qr.SVG
+	opts.Background = color.RGBA{R: 0xff, G: 0xee, B: 0xdd, A: 0xff}
+
+	b, err := qr.Encode(content, opts)
+
+	require.NoError(t, err)
+	svg := string(b)
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, `width="256" height="256"`)
+	assert.Contains(t, svg, `fill="#ffeedd"`)
+	assert.Contains(t, svg, `fill="#000000"`)
+	// Искатель в левом верхнем углу начинается сразу за полем в 4 модуля.
+	assert.Contains(t, svg, "M4 4h1v1h-1z")
+}
+
+func TestEncode_InvalidOptions(t *testing.T) {
+	tests := map[string]struct {
+		modify  func(*qr.Options)
+		wantErr error
+	}{
+		"format":     {modify: func(o *qr.Options) { o.Format = "gif" }, wantErr: qr.ErrInvalidFormat},
+		"level":      {modify: func(o *qr.Options) { o.Level = "X" }, wantErr: qr.ErrInvalidLevel},
+		"small size": {modify: func(o *qr.Options) { o.Size = 10 }, wantErr: qr.ErrInvalidSize},
+		"big size":   {modify: func(o *qr.Options) { o.Size = 5000 }, wantErr: qr.ErrInvalidSize},
+		"margin":     {modify: func(o *qr.Options) { o.Margin = -1 }, wantErr: qr.ErrInvalidMargin},
+		"too dense": {
+			modify:  func(o *qr.Options) { o.Size = qr.MinSize; o.Level = "H"; o.Margin = qr.MaxMargin },
+			wantErr: qr.ErrSizeTooSmall,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			tt.modify(&opts)
+
+			_, err := qr.Encode(content, opts)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := qr.ParseColor("#1a2B3c")
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}, c)
+
+	c, err = qr.ParseColor("ffffff")
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, c)
+
+	for _, s := range []string{"", "fff", "red", "gggggg", "#1234567"} {
+		_, err := qr.ParseColor(s)
+		assert.ErrorIs(t, err, qr.ErrInvalidColor, s)
+	}
+}
+
+func decodePNG(t *testing.T, opts qr.Options) image.Image {
+	t.Helper()
+
+	b, err := qr.Encode(content, opts)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	return img
+}
+
+// firstDark возвращает координату первого темного пикселя на диагонали.
+func firstDark(img image.Image, fg color.RGBA) int {
+	for i := range img.Bounds().Dx() {
+		if rgba(img.At(i, i)) == fg {
+			return i
+		}
+	}
+	return -1
+}
+
+func rgba(c color.Color) color.RGBA {
+	r, g, b, a := c.RGBA()
+	return color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)}
+}
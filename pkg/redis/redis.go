@@ -4,10 +4,13 @@ package redis
 import (
 	"time"
 
+	goredis "github.com/go-redis/redis/v8"
 	"github.com/wb-go/wbf/redis"
 )
 
 type RDB = redis.Client
+type Pipeliner = goredis.Pipeliner
+type IntCmd = goredis.IntCmd
 
 type Config struct {
 	Addr     string        `mapstructure:"addr"`
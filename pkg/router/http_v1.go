@@ -2,12 +2,15 @@
 package router
 
 import (
+	"fmt"
+
 	"github.com/wb-go/wbf/ginext"
 )
 
 type Router = ginext.Engine
 type Context = ginext.Context
 type H = ginext.H
+type HandlerFunc = ginext.HandlerFunc
 
 type Handler interface {
 	Register(router *Router)
@@ -15,8 +18,16 @@ type Handler interface {
 
 type Config struct {
 	GinMode string `mapstructure:"gin_mode"`
+	// TrustedProxies - адреса и подсети прокси, которым можно верить в
+	// X-Forwarded-For и X-Real-IP. Пусто - IP клиента берется из соединения,
+	// и подделать его заголовком нельзя.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
-func New(cfg Config) *Router {
-	return ginext.New(cfg.GinMode)
+func New(cfg Config) (*Router, error) {
+	r := ginext.New(cfg.GinMode)
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	return r, nil
 }
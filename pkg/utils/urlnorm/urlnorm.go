@@ -0,0 +1,40 @@
+// Package urlnorm приводит эквивалентные URL к одному виду.
+package urlnorm
+
+import (
+	"net/url"
+	"strings"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize приводит схему и хост к нижнему регистру, убирает порт по
+// умолчанию и заменяет пустой путь на "/". Путь, query и fragment не
+// меняются, так как сервер может различать их регистр и порядок.
+func Normalize(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		// IPv6-адрес должен остаться в скобках.
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if u.Path == "" && u.Opaque == "" {
+		u.Path = "/"
+	}
+
+	return u.String(), nil
+}
@@ -0,0 +1,26 @@
+package urlnorm_test
+
+import (
+	"testing"
+
+	"github.com/adexcell/shortener/pkg/utils/urlnorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"HTTPS://Example.COM":                "https://example.com/",
+		"https://example.com:443/a":          "https://example.com/a",
+		"http://example.com:80/?q=1":         "http://example.com/?q=1",
+		"http://example.com:8080/Path":       "http://example.com:8080/Path",
+		"https://example.com/a?b=2&a=1#Frag": "https://example.com/a?b=2&a=1#Frag",
+		"http://[::1]:80/x":                  "http://[::1]/x",
+		"http://[::1]:8080/x":                "http://[::1]:8080/x",
+	}
+
+	for in, want := range cases {
+		got, err := urlnorm.Normalize(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+}
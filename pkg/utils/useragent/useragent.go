@@ -0,0 +1,226 @@
+// Package useragent определяет браузер, платформу и класс устройства по
+// заголовку User-Agent. Разбор эвристический: он покрывает распространенные браузеры и
+// не претендует на полноту специализированных библиотек.
+package useragent
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Браузеры.
+const (
+	Chrome  = "chrome"
+	Firefox = "firefox"
+	Safari  = "safari"
+	Edge    = "edge"
+	Opera   = "opera"
+	Samsung = "samsung"
+	Yandex  = "yandex"
+)
+
+// Платформы.
+const (
+	IOS      = "ios"
+	Android  = "android"
+	Windows  = "windows"
+	MacOS    = "macos"
+	Linux    = "linux"
+	ChromeOS = "chromeos"
+)
+
+// Классы устройств.
+const (
+	Mobile  = "mobile"
+	Tablet  = "tablet"
+	Desktop = "desktop"
+)
+
+// Agent - результат разбора User-Agent. Пустое поле означает, что значение
+// определить не удалось.
+type Agent struct {
+	Browser string
+	// Version - мажорная версия браузера, например "120"
+	Version string
+	OS      string
+	Device  string
+	// Bot - запрос от краулера или утилиты, а не от человека
+	Bot bool
+	// BotName - имя бота, например "slack" или "google"
+	BotName string
+}
+
+// Parse разбирает заголовок User-Agent.
+func Parse(ua string) Agent {
+	if ua == "" {
+		return Agent{}
+	}
+
+	var a Agent
+	a.Browser, a.Version = parseBrowser(ua)
+	a.OS = parseOS(ua)
+	a.Device = parseDevice(ua, a.OS)
+	a.BotName = parseBot(ua)
+	a.Bot = a.BotName != ""
+
+	return a
+}
+
+// browserTokens - признаки браузеров в порядке проверки: Edge, Opera и
+// другие браузеры на Chromium пишут в UA и "Chrome/", а Chrome - "Safari/",
+// поэтому частные признаки идут раньше общих. Версия берется после токена.
+var browserTokens = []struct {
+	token   string
+	browser string
+}{
+	{"Edg/", Edge},
+	{"EdgA/", Edge},
+	{"EdgiOS/", Edge},
+	{"OPR/", Opera},
+	{"SamsungBrowser/", Samsung},
+	{"YaBrowser/", Yandex},
+	{"Firefox/", Firefox},
+	{"FxiOS/", Firefox},
+	{"Chrome/", Chrome},
+	{"CriOS/", Chrome},
+	// Safari указывает свою версию в "Version/", а "Safari/" - версия WebKit.
+	{"Version/", Safari},
+}
+
+func parseBrowser(ua string) (string, string) {
+	for _, t := range browserTokens {
+		i := strings.Index(ua, t.token)
+		if i < 0 {
+			continue
+		}
+		if t.browser == Safari && !strings.Contains(ua, "Safari/") {
+			continue
+		}
+		return t.browser, majorVersion(ua[i+len(t.token):])
+	}
+
+	return "", ""
+}
+
+// majorVersion возвращает число в начале строки версии: "120.0.6099" -> "120".
+func majorVersion(v string) string {
+	end := strings.IndexFunc(v, func(r rune) bool { return !unicode.IsDigit(r) })
+	if end < 0 {
+		return v
+	}
+	return v[:end]
+}
+
+// OtherBot - имя бота, которого выдает только общий признак вроде "bot"
+// или "crawler".
+const OtherBot = "other"
+
+// botTokens - подстроки UA ботов в нижнем регистре и имена ботов. Первыми
+// идут сервисы превью ссылок (unfurlers), которые открывают ссылку сразу
+// после публикации в мессенджере или соцсети, затем поисковые краулеры и
+// HTTP-утилиты.
+var botTokens = []struct {
+	token string
+	name  string
+}{
+	{"slackbot", "slack"},
+	{"telegrambot", "telegram"},
+	{"twitterbot", "twitter"},
+	{"facebookexternalhit", "facebook"},
+	{"facebookcatalog", "facebook"},
+	{"facebot", "facebook"},
+	{"discordbot", "discord"},
+	{"whatsapp", "whatsapp"},
+	{"linkedinbot", "linkedin"},
+	{"skypeuripreview", "skype"},
+	{"vkshare", "vk"},
+	{"redditbot", "reddit"},
+	{"pinterestbot", "pinterest"},
+	{"embedly", "embedly"},
+	{"googlebot", "google"},
+	{"bingbot", "bing"},
+	{"yandexbot", "yandex"},
+	{"applebot", "apple"},
+	{"headless", "headless"},
+	{"curl/", "curl"},
+	{"wget/", "wget"},
+	{"python-requests", "python"},
+	{"go-http-client", "go"},
+	{"okhttp", "okhttp"},
+	{"java/", "java"},
+}
+
+// botWords - общие признаки ботов. Подстрокой их искать нельзя: "bot" есть
+// в названии телефонов CUBOT, поэтому они проверяются по словам UA.
+var botWords = []string{"bot", "crawler", "spider", "slurp"}
+
+// parseBot возвращает имя бота или "", если UA похож на браузер человека.
+func parseBot(ua string) string {
+	ua = strings.ToLower(ua)
+	for _, t := range botTokens {
+		if strings.Contains(ua, t.token) {
+			return t.name
+		}
+	}
+	if hasBotWord(ua) {
+		return OtherBot
+	}
+	return ""
+}
+
+// hasBotWord сообщает, есть ли в UA общий признак бота: отдельное слово
+// ("Yahoo! Slurp", "/bot.html") или окончание продукта с версией
+// ("SemrushBot/7", "Baiduspider/2.0"). Модель устройства вроде "CUBOT X30"
+// версии не имеет и под правило не попадает.
+func hasBotWord(ua string) bool {
+	words := strings.FieldsFunc(ua, func(r rune) bool {
+		return r != '/' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		product, _, versioned := strings.Cut(word, "/")
+		for _, w := range botWords {
+			if product == w || versioned && strings.HasSuffix(product, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseOS(ua string) string {
+	switch {
+	// Проверки идут от частного к общему: UA iOS содержит "like Mac OS X",
+	// а Android и ChromeOS - "Linux".
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iPod"):
+		return IOS
+	case strings.Contains(ua, "Android"):
+		return Android
+	case strings.Contains(ua, "CrOS"):
+		return ChromeOS
+	case strings.Contains(ua, "Windows"):
+		return Windows
+	case strings.Contains(ua, "Macintosh"), strings.Contains(ua, "Mac OS X"):
+		return MacOS
+	case strings.Contains(ua, "Linux"), strings.Contains(ua, "X11"):
+		return Linux
+	default:
+		return ""
+	}
+}
+
+func parseDevice(ua, os string) string {
+	switch {
+	case strings.Contains(ua, "iPad"), strings.Contains(ua, "Tablet"):
+		return Tablet
+	// Android-планшеты не добавляют "Mobile" в UA.
+	case os == Android && !strings.Contains(ua, "Mobile"):
+		return Tablet
+	case strings.Contains(ua, "Mobi"), strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPod"),
+		strings.Contains(ua, "Windows Phone"):
+		return Mobile
+	case os != "":
+		return Desktop
+	default:
+		return ""
+	}
+}
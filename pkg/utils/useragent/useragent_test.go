@@ -0,0 +1,63 @@
+package useragent_test
+
+import (
+	"testing"
+
+	"github.com/adexcell/shortener/pkg/utils/useragent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]useragent.Agent{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1": {
+			Browser: useragent.Safari, Version: "17", OS: useragent.IOS, Device: useragent.Mobile,
+		},
+		"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1": {
+			Browser: useragent.Safari, Version: "16", OS: useragent.IOS, Device: useragent.Tablet,
+		},
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36": {
+			Browser: useragent.Chrome, Version: "120", OS: useragent.Android, Device: useragent.Mobile,
+		},
+		"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36": {
+			Browser: useragent.Chrome, Version: "120", OS: useragent.Android, Device: useragent.Tablet,
+		},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36": {
+			Browser: useragent.Chrome, Version: "120", OS: useragent.Windows, Device: useragent.Desktop,
+		},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15": {
+			Browser: useragent.Safari, Version: "17", OS: useragent.MacOS, Device: useragent.Desktop,
+		},
+		"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0": {
+			Browser: useragent.Firefox, Version: "120", OS: useragent.Linux, Device: useragent.Desktop,
+		},
+		"Mozilla/5.0 (X11; CrOS x86_64 15633.69.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36": {
+			Browser: useragent.Chrome, Version: "119", OS: useragent.ChromeOS, Device: useragent.Desktop,
+		},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.61": {
+			Browser: useragent.Edge, Version: "120", OS: useragent.Windows, Device: useragent.Desktop,
+		},
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/119.0.6045.169 Mobile/15E148 Safari/604.1": {
+			Browser: useragent.Chrome, Version: "119", OS: useragent.IOS, Device: useragent.Mobile,
+		},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 YaBrowser/23.11.0.0 Safari/537.36": {
+			Browser: useragent.Yandex, Version: "23", OS: useragent.Windows, Device: useragent.Desktop,
+		},
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":  {Bot: true, BotName: "google"},
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)":                {Bot: true, BotName: "slack"},
+		"TelegramBot (like TwitterBot)":                                             {Bot: true, BotName: "telegram"},
+		"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)": {Bot: true, BotName: "facebook"},
+		"Twitterbot/1.0": {Bot: true, BotName: "twitter"},
+		"Mozilla/5.0 (compatible; SemrushBot/7~bl; +http://www.semrush.com/bot.html)": {Bot: true, BotName: useragent.OtherBot},
+		"curl/8.4.0": {Bot: true, BotName: "curl"},
+		"Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)": {Bot: true, BotName: useragent.OtherBot},
+		"Mozilla/5.0 (compatible; Yahoo! Slurp; http://help.yahoo.com/help/us/ysearch/slurp)": {Bot: true, BotName: useragent.OtherBot},
+		"Mozilla/5.0 (Linux; Android 10; CUBOT X30) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36": {
+			Browser: useragent.Chrome, Version: "120", OS: useragent.Android, Device: useragent.Mobile,
+		},
+		"": {},
+	}
+
+	for ua, want := range cases {
+		assert.Equal(t, want, useragent.Parse(ua), ua)
+	}
+}